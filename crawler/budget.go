@@ -0,0 +1,172 @@
+package crawler
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+type Budget int
+
+const (
+	PagesBudget Budget = iota + 1
+	BytesBudget
+	DurationBudget
+	ErrorRateBudget
+)
+
+func (b Budget) String() string {
+	switch b {
+	case PagesBudget:
+		return "pages"
+	case BytesBudget:
+		return "bytes"
+	case DurationBudget:
+		return "duration"
+	case ErrorRateBudget:
+		return "error rate"
+	default:
+		return "unknown"
+	}
+}
+
+// BudgetExceededError is returned by Feed when the crawl was stopped by one of the Options budgets
+type BudgetExceededError struct {
+	Budget Budget
+
+	// Usage at the moment the budget was exceeded
+	Pages    int
+	Bytes    int64
+	Requests int
+	Errors   int
+	Elapsed  time.Duration
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("crawl budget exceeded: %s; pages = %d, bytes = %d, requests = %d, errors = %d, elapsed = %s",
+		e.Budget, e.Pages, e.Bytes, e.Requests, e.Errors, e.Elapsed)
+}
+
+const defaultErrorRateMinRequests = 20
+
+type crawlBudget struct {
+	ops *Options
+
+	lock     sync.Mutex
+	started  time.Time
+	pages    int
+	bytes    int64
+	requests int
+	errors   int
+	exceeded *BudgetExceededError
+}
+
+func newCrawlBudget(ops *Options) *crawlBudget {
+	return &crawlBudget{
+		ops:     ops,
+		started: time.Now(),
+	}
+}
+
+func (b *crawlBudget) exceed(budget Budget) {
+	if b.exceeded != nil {
+		return
+	}
+
+	b.exceeded = &BudgetExceededError{
+		Budget:   budget,
+		Pages:    b.pages,
+		Bytes:    b.bytes,
+		Requests: b.requests,
+		Errors:   b.errors,
+		Elapsed:  time.Since(b.started),
+	}
+}
+
+// admit reserves a page of the budget; false once any budget is exceeded
+func (b *crawlBudget) admit() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.exceeded != nil {
+		return false
+	}
+
+	if b.ops.MaxDuration > 0 && time.Since(b.started) > b.ops.MaxDuration {
+		b.exceed(DurationBudget)
+		return false
+	}
+
+	if b.ops.MaxPages > 0 && b.pages >= b.ops.MaxPages {
+		b.exceed(PagesBudget)
+		return false
+	}
+
+	b.pages++
+	return true
+}
+
+func (b *crawlBudget) stopped() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.exceeded != nil
+}
+
+// done accounts a finished request
+func (b *crawlBudget) done(failed bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.requests++
+	if failed {
+		b.errors++
+	}
+
+	minRequests := b.ops.ErrorRateMinRequests
+	if minRequests == 0 {
+		minRequests = defaultErrorRateMinRequests
+	}
+
+	if b.ops.MaxErrorRate > 0 && b.requests >= minRequests && float64(b.errors)/float64(b.requests) > b.ops.MaxErrorRate {
+		b.exceed(ErrorRateBudget)
+	}
+}
+
+func (b *crawlBudget) read(n int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.bytes += int64(n)
+
+	if b.ops.MaxBytes > 0 && b.bytes >= b.ops.MaxBytes {
+		b.exceed(BytesBudget)
+	}
+}
+
+func (b *crawlBudget) err() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.exceeded == nil {
+		return nil
+	}
+	return b.exceeded
+}
+
+// reader accounts bytes read; pages in flight are read completely even if the budget is exceeded
+func (b *crawlBudget) reader(r io.Reader) io.Reader {
+	return &crawlBudgetReader{b: b, r: r}
+}
+
+type crawlBudgetReader struct {
+	b *crawlBudget
+	r io.Reader
+}
+
+func (r *crawlBudgetReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.b.read(n)
+	return n, err
+}
@@ -0,0 +1,85 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCrawlBudget(t *testing.T) {
+	var served int64
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, q *http.Request) {
+		n := atomic.AddInt64(&served, 1)
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprintf(w, "http://%s/%d-a http://%s/%d-b", q.Host, n, q.Host, n)
+	}))
+	defer srv.Close()
+
+	cr := newTestCrawler(t, Options{
+		MaxPages: 5,
+	})
+
+	_, err := cr.Feed(context.Background(), 0, srv.URL)
+
+	budgetErr, ok := err.(*BudgetExceededError)
+	if !ok || budgetErr.Budget != PagesBudget || budgetErr.Pages != 5 {
+		t.Log("bad budget error; actual", err)
+		t.Fail()
+	}
+
+	if served != 5 {
+		t.Log("bad number of requests; actual", served, "expected", 5)
+		t.Fail()
+	}
+}
+
+func TestCrawlBudgetErrorRate(t *testing.T) {
+	b := newCrawlBudget(&Options{MaxErrorRate: 0.5, ErrorRateMinRequests: 4})
+
+	for _, failed := range []bool{true, true, true} {
+		b.done(failed)
+	}
+	if b.stopped() {
+		t.Log("stopped before min requests are done")
+		t.Fail()
+	}
+
+	b.done(false)
+	if err, ok := b.err().(*BudgetExceededError); !ok || err.Budget != ErrorRateBudget {
+		t.Log("bad budget error; actual", b.err())
+		t.Fail()
+	}
+}
+
+func TestCrawlBudgetErrorRateCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, q *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/html")
+	}))
+	defer srv.Close()
+
+	cr := newTestCrawlerFunc(func(page *Page, link string, pos int, err error) {
+	}, Options{
+		ParallelRequestsPerHost: 1,
+		MaxErrorRate:            0.5,
+	})
+
+	links := make([]string, 50)
+	for i := range links {
+		links[i] = fmt.Sprintf("%s/%d", srv.URL, i)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	//> Requests waiting for the host are aborted with the crawl, which is not a reason to exceed the error rate
+	if _, err := cr.Feed(ctx, 1, links...); err != context.DeadlineExceeded {
+		t.Log("bad error; actual", err, "expected", context.DeadlineExceeded)
+		t.Fail()
+	}
+}
@@ -0,0 +1,65 @@
+package crawler
+
+import (
+	"context"
+	"io"
+	"mime"
+	"strings"
+)
+
+// ContentHandler extracts titles and links from a response body of a particular media type
+type ContentHandler interface {
+	HandleContent(ctx context.Context, r io.Reader, yieldTitle func(pos int, title string) error, yieldLink func(pos int, link Link) error) error
+}
+
+func (f FilterFunc) HandleContent(ctx context.Context, r io.Reader, yieldTitle func(pos int, title string) error, yieldLink func(pos int, link Link) error) error {
+	return f(ctx, r, yieldTitle, yieldLink)
+}
+
+// Handle registers handler for responses matching the media type pattern.
+// Pattern is either an exact media type ("text/html"), a structured syntax suffix ("application/*+xml"),
+// a type wildcard ("text/*") or "*/*"; the most specific matching pattern wins.
+// Nil handler unregisters the pattern. Must not be called concurrently with Feed.
+func (cr *Crawler) Handle(pattern string, handler ContentHandler) {
+	pattern = strings.ToLower(pattern)
+
+	if handler == nil {
+		delete(cr.handlers, pattern)
+	} else {
+		cr.handlers[pattern] = handler
+	}
+}
+
+func (cr *Crawler) contentHandler(contentType string) ContentHandler {
+	if contentType == "" {
+		// TODO Try to detect CT automatically
+		// http.DetectContentType()
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+
+	if h, ok := cr.handlers[mediaType]; ok {
+		return h
+	}
+
+	typ, subtype := mediaType, ""
+	if i := strings.IndexByte(mediaType, '/'); i >= 0 {
+		typ, subtype = mediaType[:i], mediaType[i+1:]
+	}
+
+	if i := strings.LastIndexByte(subtype, '+'); i >= 0 {
+		if h, ok := cr.handlers[typ+"/*"+subtype[i:]]; ok {
+			return h
+		}
+	}
+
+	if h, ok := cr.handlers[typ+"/*"]; ok {
+		return h
+	}
+
+	return cr.handlers["*/*"]
+}
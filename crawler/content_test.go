@@ -0,0 +1,81 @@
+package crawler
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+)
+
+type namedHandler string
+
+func (h namedHandler) HandleContent(ctx context.Context, r io.Reader, yieldTitle func(pos int, title string) error, yieldLink func(pos int, link Link) error) error {
+	return nil
+}
+
+func TestContentHandlerMatching(t *testing.T) {
+	cr := &Crawler{handlers: map[string]ContentHandler{}}
+
+	cr.Handle("text/html", namedHandler("html"))
+	cr.Handle("Application/*+XML", namedHandler("xml"))
+	cr.Handle("text/*", namedHandler("text"))
+	cr.Handle("application/json", namedHandler("json"))
+	cr.Handle("application/json", nil)
+
+	for contentType, expected := range map[string]ContentHandler{
+		"text/html; charset=utf-8": namedHandler("html"),
+		"application/atom+xml":     namedHandler("xml"),
+		"text/css":                 namedHandler("text"),
+		"application/json":         nil,
+		"":                         nil,
+	} {
+		if actual := cr.contentHandler(contentType); actual != expected {
+			t.Log("bad handler for", contentType, "; actual", actual, "expected", expected)
+			t.Fail()
+		}
+	}
+
+	cr.Handle("*/*", namedHandler("any"))
+	if actual := cr.contentHandler("application/json"); actual != namedHandler("any") {
+		t.Log("bad fallback handler; actual", actual)
+		t.Fail()
+	}
+}
+
+func TestCSSFilter(t *testing.T) {
+	_, links := collectFilter(t, CSSFilter(), `@import "base.css";
+@import url('print.css') print;
+/* background: url(commented.png); */
+body { background: url( "img/bg.png" ) no-repeat; }
+.logo { background-image: URL(/logo.svg); }`)
+
+	if expected := []string{"base.css", "print.css", "img/bg.png", "/logo.svg"}; !reflect.DeepEqual(links, expected) {
+		t.Log("bad links; actual", links, "expected", expected)
+		t.Fail()
+	}
+}
+
+func TestJSONFilter(t *testing.T) {
+	_, links := collectFilter(t, JSONFilter(), `{
+	"https://example.com/key": "not a link",
+	"items": [
+		{"url": "https://example.com/1", "id": 1},
+		{"url": "/relative", "nested": {"href": "http://example.com/2"}}
+	],
+	"next": "https://example.com/?page=2"
+}`)
+
+	if expected := []string{"https://example.com/1", "http://example.com/2", "https://example.com/?page=2"}; !reflect.DeepEqual(links, expected) {
+		t.Log("bad links; actual", links, "expected", expected)
+		t.Fail()
+	}
+}
+
+func TestTextFilter(t *testing.T) {
+	_, links := collectFilter(t, TextFilter(), "See https://example.com/a, and (http://example.com/b).\nhttps://en.wikipedia.org/wiki/NOP_(code)\n")
+
+	if expected := []string{"https://example.com/a", "http://example.com/b", "https://en.wikipedia.org/wiki/NOP_(code)"}; !reflect.DeepEqual(links, expected) {
+		t.Log("bad links; actual", links, "expected", expected)
+		t.Fail()
+	}
+}
@@ -0,0 +1,164 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+)
+
+// Meta is arbitrary data attached to a submitted link
+type Meta map[string]interface{}
+
+// ErrCrawlClosed is returned by Submit after Close
+var ErrCrawlClosed = errors.New("crawl closed")
+
+// crawl is the state shared by all the links of a single Start or Feed call
+type crawl struct {
+	*Crawler
+
+	ctx context.Context
+	wg  sync.WaitGroup
+
+	lock         sync.Mutex
+	closed       bool
+	done         chan struct{}
+	sitemapHosts map[string]bool
+	requested    map[string]bool
+
+	scopeCounter scopeCounter
+	hostBudgets  *hostBudgets
+	budget       *crawlBudget
+	stats        *crawlStats
+}
+
+func (cr *Crawler) newCrawl() *crawl {
+	return &crawl{
+		Crawler:      cr,
+		ctx:          context.Background(),
+		done:         make(chan struct{}),
+		sitemapHosts: map[string]bool{},
+		requested:    map[string]bool{},
+		scopeCounter: scopeCounter{
+			hosts: map[string]map[string]bool{},
+		},
+		hostBudgets: newHostBudgets(cr.ops.HostLimits, cr.ops.HostLimitsOverrides),
+		budget:      newCrawlBudget(&cr.ops),
+		stats:       newCrawlStats(),
+	}
+}
+
+// fail accounts the error and reports it; requested is true if the error is a failed request to the host
+func (cr *crawl) fail(host string, requested bool, page *Page, link string, pos int, err error) {
+	cr.stats.error(host, requested, err)
+	cr.yieldError(page, link, pos, err)
+}
+
+// dedup tells if the link should be requested; false if Options.Dedup is set and the link was requested already
+func (cr *crawl) dedup(link string) bool {
+	if !cr.ops.Dedup {
+		return true
+	}
+
+	cr.lock.Lock()
+	defer cr.lock.Unlock()
+
+	if cr.requested[link] {
+		return false
+	}
+	cr.requested[link] = true
+	return true
+}
+
+// Crawl is a long-running crawl accepting links until it is closed
+type Crawl struct {
+	c *crawl
+}
+
+// Start starts a crawl; links are added with Submit.
+// All the links of the crawl share its budgets, scope counters and, if Options.Dedup is set, requested links
+func (cr *Crawler) Start(ctx context.Context) *Crawl {
+	c := cr.newCrawl()
+	c.ctx = ctx
+	return &Crawl{c: c}
+}
+
+// Submit adds a seed link to the crawl and returns immediately. Zero depth means Options.Depth.
+// Meta is passed to callbacks of every page crawled from the link.
+// Returns ErrCrawlClosed after Close
+func (c *Crawl) Submit(link string, depth int, meta Meta) error {
+	cr := c.c
+
+	if depth == 0 {
+		depth = cr.ops.Depth
+	}
+
+	seed, err := url.Parse(link)
+	if err != nil {
+		return err
+	}
+
+	seed = cr.ops.URLNormalizer.Normalize(seed)
+	link = seed.String()
+
+	cr.lock.Lock()
+	defer cr.lock.Unlock()
+
+	if cr.closed {
+		return ErrCrawlClosed
+	}
+
+	cr.wg.Add(1)
+	go func() {
+		defer cr.wg.Done()
+		cr.handle(cr.ctx, seed, depth, &Page{URL: link, Depth: depth, Meta: meta})
+	}()
+
+	if cr.ops.Sitemaps && !cr.sitemapHosts[seed.Host] {
+		cr.sitemapHosts[seed.Host] = true
+
+		cr.wg.Add(1)
+		go func() {
+			defer cr.wg.Done()
+			cr.handleSitemaps(cr.ctx, seed, depth, depth, meta)
+		}()
+	}
+
+	return nil
+}
+
+// Close stops accepting new links; links submitted before are crawled to the end
+func (c *Crawl) Close() {
+	cr := c.c
+
+	cr.lock.Lock()
+	defer cr.lock.Unlock()
+
+	if !cr.closed {
+		cr.closed = true
+		close(cr.done)
+	}
+}
+
+// Wait blocks until the crawl is closed and all its links are crawled, or the context is done.
+// Returns *BudgetExceededError if the crawl was stopped by one of the Options budgets,
+// or the context error if the crawl was aborted; the result is returned in any case.
+func (c *Crawl) Wait() (*CrawlResult, error) {
+	cr := c.c
+
+	select {
+	case <-cr.done:
+	case <-cr.ctx.Done():
+		c.Close()
+	}
+
+	//> No more wg.Add once closed
+	cr.wg.Wait()
+
+	result := cr.stats.snapshot()
+
+	if err := cr.budget.err(); err != nil {
+		return result, err
+	}
+	return result, cr.ctx.Err()
+}
@@ -0,0 +1,206 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestCrawler creates a crawler following every link TextFilter finds and failing the test on any error
+func newTestCrawler(t *testing.T, ops Options) *Crawler {
+	return newTestCrawlerFunc(func(page *Page, link string, pos int, err error) {
+		t.Log("unexpected error", page.URL, link, err)
+		t.Fail()
+	}, ops)
+}
+
+func newTestCrawlerFunc(yieldError YieldErrorFunc, ops Options) *Crawler {
+	return New(TextFilter(), func(page *Page, pos int, title string) {
+	}, func(page *Page, pos int, found Link, link *url.URL, rel Relation) bool {
+		return true
+	}, yieldError, ops)
+}
+
+func TestStart(t *testing.T) {
+	var served int64
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, q *http.Request) {
+		atomic.AddInt64(&served, 1)
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprintf(w, "http://%s/shared", q.Host)
+	}))
+	defer srv.Close()
+
+	cr := newTestCrawler(t, Options{
+		Dedup: true,
+	})
+
+	c := cr.Start(context.Background())
+
+	for _, link := range []string{srv.URL + "/a", srv.URL + "/b", srv.URL + "/a"} {
+		if err := c.Submit(link, 2, nil); err != nil {
+			t.Log("unexpected submit error", err)
+			t.Fail()
+		}
+	}
+
+	c.Close()
+
+	if err := c.Submit(srv.URL+"/c", 2, nil); err != ErrCrawlClosed {
+		t.Log("bad submit error after close; actual", err, "expected", ErrCrawlClosed)
+		t.Fail()
+	}
+
+	result, err := c.Wait()
+	if err != nil {
+		t.Log("unexpected error", err)
+		t.Fail()
+	}
+
+	//> /a, /b and /shared, each once
+	if n := atomic.LoadInt64(&served); n != 3 || result.Pages != 3 {
+		t.Log("bad number of requests; actual", n, result.Pages, "expected", 3)
+		t.Fail()
+	}
+}
+
+func TestStartCanceled(t *testing.T) {
+	cr := newTestCrawlerFunc(func(page *Page, link string, pos int, err error) {
+	}, Options{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	//> Not closed, so only the context ends the crawl
+	if _, err := cr.Start(ctx).Wait(); err != context.DeadlineExceeded {
+		t.Log("bad error; actual", err, "expected", context.DeadlineExceeded)
+		t.Fail()
+	}
+}
+
+func TestFeedMeta(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, q *http.Request) {
+		if q.URL.Path == "/missing" {
+			http.NotFound(w, q)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprintf(w, "http://%s/child http://%s/missing", q.Host, q.Host)
+	}))
+	defer srv.Close()
+
+	var (
+		lock  sync.Mutex
+		metas []Meta
+	)
+	record := func(page *Page) {
+		lock.Lock()
+		defer lock.Unlock()
+		metas = append(metas, page.Meta)
+	}
+
+	cr := New(TextFilter(), func(page *Page, pos int, title string) {
+	}, func(page *Page, pos int, found Link, link *url.URL, rel Relation) bool {
+		record(page)
+		return true
+	}, func(page *Page, link string, pos int, err error) {
+		record(page)
+	}, Options{
+		Dedup: true,
+	})
+
+	if _, err := cr.FeedMeta(context.Background(), 3, Meta{"job": "42"}, srv.URL+"/"); err != nil {
+		t.Log("unexpected error", err)
+		t.Fail()
+	}
+
+	//> 2 links of the seed, 2 links of /child, and the error of /missing
+	if len(metas) != 5 {
+		t.Log("bad number of callbacks; actual", len(metas), "expected", 5)
+		t.Fail()
+	}
+	for _, meta := range metas {
+		if meta["job"] != "42" {
+			t.Log("bad meta; actual", meta)
+			t.Fail()
+		}
+	}
+}
+
+func TestPagePath(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, q *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch q.URL.Path {
+		case "/":
+			_, _ = fmt.Fprint(w, "/a")
+		case "/a":
+			_, _ = fmt.Fprint(w, "/b")
+		default:
+			_, _ = fmt.Fprint(w, "/c")
+		}
+	}))
+	defer srv.Close()
+
+	filter := func(ctx context.Context, r io.Reader, yieldTitle func(pos int, title string) error, yieldLink func(pos int, link Link) error) error {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		return yieldLink(0, Link{Href: string(data), Text: "to " + string(data)})
+	}
+
+	var (
+		lock  sync.Mutex
+		pages = map[string]*Page{}
+	)
+
+	cr := New(filter, func(page *Page, pos int, title string) {
+	}, func(page *Page, pos int, found Link, link *url.URL, rel Relation) bool {
+		lock.Lock()
+		defer lock.Unlock()
+		pages[page.URL] = page
+		return true
+	}, func(page *Page, link string, pos int, err error) {
+		t.Log("unexpected error", page.URL, link, err)
+		t.Fail()
+	}, Options{})
+
+	if _, err := cr.Feed(context.Background(), 3, srv.URL+"/"); err != nil {
+		t.Log("unexpected error", err)
+		t.Fail()
+	}
+
+	page := pages[srv.URL+"/b"]
+	if page == nil {
+		t.Fatal("page not crawled")
+	}
+
+	if expected := []string{srv.URL + "/", srv.URL + "/a"}; !reflect.DeepEqual(page.Path, expected) {
+		t.Log("bad path; actual", page.Path, "expected", expected)
+		t.Fail()
+	}
+
+	if page.Level != 2 || page.Depth != 1 || page.Referer() != srv.URL+"/a" {
+		t.Log("bad level; actual", page.Level, page.Depth, page.Referer())
+		t.Fail()
+	}
+
+	if expected := (Link{Href: "/b", Text: "to /b"}); !reflect.DeepEqual(page.Anchor, expected) {
+		t.Log("bad anchor; actual", page.Anchor, "expected", expected)
+		t.Fail()
+	}
+
+	if seed := pages[srv.URL+"/"]; seed == nil || seed.Level != 0 || len(seed.Path) != 0 || seed.Referer() != "" {
+		t.Log("bad seed page; actual", seed)
+		t.Fail()
+	}
+}
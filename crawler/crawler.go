@@ -2,58 +2,214 @@ package crawler
 
 import (
 	"context"
-	"errors"
-	"fmt"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/themakers/simple-crawler/keyed_pool"
+	"github.com/themakers/simple-crawler/psl"
+	"github.com/themakers/simple-crawler/urlnorm"
 	"io"
+	"math"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
-	"strings"
 	"sync"
 	"time"
 )
 
-type FilterFunc func(ctx context.Context, r io.Reader, yieldTitle func(pos int, title string) error, yieldLink func(pos int, link string) error) error
+// Link is a link found by a filter. Filters of this package fill Href only, RSSFilter fills Rel and Tag too;
+// see the filters package for HTML filters filling the rest
+type Link struct {
+	// As found in the content, not resolved nor normalized
+	Href string
 
-type YieldURLFunc func(depth, pos int, origin string, originalLink string, link *url.URL, external bool) bool
-type YieldTitleFunc func(depth, pos int, origin string, title string)
-type YieldErrorFunc func(origin, link string, pos int, err error)
+	// Anchor text; empty if the content has no such thing or the filter doesn't extract it
+	Text string
+
+	// Values of the title and rel attributes
+	Title string
+	Rel   string
+
+	// Name of the element the link is found in, e.g. "a" or "link"; empty if unknown
+	Tag string
+
+	// All the attributes of the element; nil if the filter doesn't extract them
+	Attrs map[string]string
+}
+
+type FilterFunc func(ctx context.Context, r io.Reader, yieldTitle func(pos int, title string) error, yieldLink func(pos int, link Link) error) error
+
+// Page is a page being crawled, passed to the callbacks
+type Page struct {
+	URL string
+
+	// Depth left, as passed to Feed or Submit and decremented on every hop
+	Depth int
+
+	// Meta of the seed the page was discovered from; shared by the whole crawl tree, so must not be modified
+	Meta Meta
+
+	// Link hops from the seed; zero for seeds and sitemap entries
+	Level int
+
+	// URLs of the pages the page was discovered through, from the seed (or the sitemap) to the parent; empty for seeds
+	Path []string
+
+	// Link on the parent page the page was discovered by; Href is the sitemap entry for sitemap entries, empty for seeds
+	Anchor Link
+}
+
+// Referer is the parent page URL; empty for seeds
+func (p *Page) Referer() string {
+	if len(p.Path) == 0 {
+		return ""
+	}
+	return p.Path[len(p.Path)-1]
+}
+
+// Found is the link as reported by the filter; link is found.Href resolved and normalized
+type YieldURLFunc func(page *Page, pos int, found Link, link *url.URL, rel Relation) bool
+type YieldTitleFunc func(page *Page, pos int, title string)
+type YieldErrorFunc func(page *Page, link string, pos int, err error)
+
+// Called for pages which turned out to be unchanged since the previous visit
+type YieldUnchangedFunc func(page *Page, state PageState)
 
 type Options struct {
+	// Nil for a client tuned with the transport options below
 	Client *http.Client
 
+	// Options of the default Client's transport; ignored if Client is set
+	Timeouts Timeouts
+
+	// Idle connections kept per host; zero for ParallelRequestsPerHost, or 16 if that's unlimited
+	MaxIdleConnsPerHost int
+
+	// Zero for 30 seconds
+	IdleConnTimeout time.Duration
+
+	// Stick to HTTP/1.1, which is negotiated with every host otherwise
+	DisableHTTP2 bool
+
 	UserAgent string
 
 	// Zero for unlimited depth
 	Depth int
 
-	// Zero for no limit
+	// Zero for no limit; see also HostLimits.ParallelRequests and Crawler.SetParallelRequests
 	ParallelRequestsPerHost int
+
+	// Requests in flight across all hosts; zero for no limit.
+	// Hosts take turns once it's reached, so a single busy host doesn't hold up the others; with PoolBy, servers take turns
+	MaxParallelRequests int
+
+	// Adjust requests in flight to every host between 1 and its limit above: raise while response times are stable,
+	// back off on 429 and 503 responses, timeouts and rising response times
+	AdaptiveParallelRequests bool
+
+	// Also limit requests in flight per server IP address or subnet, so virtual hosts
+	// of the same server don't get ParallelRequestsPerHost each. Hosts are resolved with a cache
+	PoolBy PoolBy
+
+	// Zero for ParallelRequestsPerHost
+	ParallelRequestsPerAddr int
+
+	// Looks up hosts for the default Client, PoolBy and PrefetchDNS; lookups are cached. Nil for SystemResolver
+	Resolver Resolver
+
+	// Cache TTL of lookups Resolver doesn't tell TTL of; zero for 5 minutes
+	ResolverTTL time.Duration
+
+	// Cache TTL of failed lookups; zero for 30 seconds
+	ResolverNegativeTTL time.Duration
+
+	// Look up hosts of links passed by YieldURLFunc in background while they wait to be requested
+	PrefetchDNS bool
+
+	// Discover seed hosts' sitemaps via robots.txt and /sitemap.xml and crawl their entries as seeds
+	Sitemaps bool
+
+	// Optional; called for every sitemap entry before it is passed to YieldURLFunc
+	YieldSitemapURL YieldSitemapURLFunc
+
+	Scope Scope
+
+	// Budget of every host; overrides are checked in order, the first matching one wins
+	HostLimits          HostLimits
+	HostLimitsOverrides []HostLimitsRule
+
+	// Budgets of a single crawl, see Feed and Start; zero for no limit.
+	// Once any of them is exceeded, no new pages are requested and requests in flight are completed
+	MaxPages    int
+	MaxBytes    int64
+	MaxDuration time.Duration
+
+	// Fraction of failed requests, from 0 to 1, checked once ErrorRateMinRequests requests are done
+	MaxErrorRate float64
+
+	// Zero for 20
+	ErrorRateMinRequests int
+
+	// Used to tell SameDomain links from External ones. Nil for psl.Default()
+	PublicSuffixList *psl.List
+
+	// Applied to every discovered link before it is passed to YieldURLFunc. Nil for urlnorm.Default
+	URLNormalizer *urlnorm.Normalizer
+
+	// Remembers ETag, Last-Modified and content hash of pages having a content handler to make conditional requests on the next visit.
+	// Pages their handler stops reading more than 1 MiB before the end are not remembered.
+	// Nil to fetch everything unconditionally
+	State StateStore
+
+	// Optional; called for pages responded with 304 Not Modified or having the same content hash as before.
+	// Links of pages responded with 304 are not crawled
+	YieldUnchanged YieldUnchangedFunc
+
+	// Request every link at most once per crawl, see Feed and Start
+	Dedup bool
 }
 
 type Crawler struct {
-	filter     FilterFunc
+	handlers   map[string]ContentHandler
 	yieldURL   YieldURLFunc
 	yieldTitle YieldTitleFunc
 	yieldError YieldErrorFunc
 	ops        Options
 
-	request func(q *http.Request) (*http.Response, error)
+	request   func(q *http.Request) (*http.Response, error)
+	hostSlots *keyed_pool.Semaphore
+	adaptive  *keyed_pool.AIMD
+	resolver  *resolver
 }
 
 func New(filter FilterFunc, yieldTitle YieldTitleFunc, yieldURL YieldURLFunc, yieldError YieldErrorFunc, ops Options) *Crawler {
 	cr := &Crawler{
-		filter:     filter,
+		handlers:   map[string]ContentHandler{},
 		yieldTitle: yieldTitle,
 		yieldURL:   yieldURL,
 		yieldError: yieldError,
 		ops:        ops,
 	}
 
+	cr.Handle("text/html", filter)
+	cr.Handle("application/xhtml+xml", filter)
+	for _, mediaType := range feedMediaTypes {
+		cr.Handle(mediaType, RSSFilter())
+	}
+
 	if cr.ops.UserAgent == "" {
 		cr.ops.UserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:73.0) Gecko/20100101 Firefox/73.0"
 	}
 
+	if cr.ops.PublicSuffixList == nil {
+		cr.ops.PublicSuffixList = psl.Default()
+	}
+
+	if cr.ops.URLNormalizer == nil {
+		cr.ops.URLNormalizer = urlnorm.Default
+	}
+
+	cr.resolver = newResolver(cr.ops.Resolver, cr.ops.ResolverTTL, cr.ops.ResolverNegativeTTL)
+
 	if cr.ops.Client == nil {
 		jar, err := cookiejar.New(nil)
 		if err != nil {
@@ -61,116 +217,212 @@ func New(filter FilterFunc, yieldTitle YieldTitleFunc, yieldURL YieldURLFunc, yi
 		}
 
 		cr.ops.Client = &http.Client{
-			Jar:     jar,
-			Timeout: 10 * time.Second,
+			Jar:       jar,
+			Transport: cr.newTransport(),
 		}
 	}
 
-	if cr.ops.ParallelRequestsPerHost == 0 {
-		cr.request = func(q *http.Request) (resp *http.Response, err error) {
-			return cr.ops.Client.Do(q)
+	var addrs *addrPool
+	if cr.ops.PoolBy != PoolByHost {
+		limit := cr.ops.ParallelRequestsPerAddr
+		if limit == 0 {
+			limit = cr.ops.ParallelRequestsPerHost
+		}
+		if limit == 0 {
+			limit = math.MaxInt32
 		}
-	} else {
-		cr.request = newRequestPool(cr.ops.Client, cr.ops.ParallelRequestsPerHost)
+
+		addrs = &addrPool{
+			by:       cr.ops.PoolBy,
+			slots:    keyed_pool.NewSemaphore(limit),
+			resolver: cr.resolver,
+		}
+
+		//> Taken along with the address slot, so hosts waiting for a busy server don't hold total slots
+		addrs.slots.SetTotalLimit(cr.ops.MaxParallelRequests)
 	}
 
+	cr.hostSlots = keyed_pool.NewSemaphoreFunc(cr.parallelRequests)
+	if addrs == nil {
+		cr.hostSlots.SetTotalLimit(cr.ops.MaxParallelRequests)
+	}
+	if cr.ops.AdaptiveParallelRequests {
+		cr.adaptive = keyed_pool.NewAIMD(cr.hostSlots, 1, cr.parallelRequests)
+	}
+
+	cr.request = newRequestPool(cr.ops.Client, cr.hostSlots, cr.adaptive, addrs)
+
 	return cr
 }
 
-func (cr *Crawler) Feed(ctx context.Context, depth int, links ...string) {
-	if depth == 0 {
-		depth = cr.ops.Depth
-	}
+// Feed crawls links and blocks until the crawl is done.
+// Returns *BudgetExceededError if the crawl was stopped by one of the Options budgets,
+// or the context error if the crawl was aborted; the result is returned in any case.
+func (cr *Crawler) Feed(ctx context.Context, depth int, links ...string) (*CrawlResult, error) {
+	return cr.FeedMeta(ctx, depth, nil, links...)
+}
+
+// FeedMeta is Feed with meta attached to every page crawled from the links
+func (cr *Crawler) FeedMeta(ctx context.Context, depth int, meta Meta, links ...string) (*CrawlResult, error) {
+	c := cr.Start(ctx)
 
-	var wg sync.WaitGroup
-	wg.Add(len(links))
 	for _, link := range links {
-		go func(link string) {
-			defer wg.Done()
-			cr.handle(ctx, depth, depth, link, "")
-		}(link)
+		if err := c.Submit(link, depth, meta); err != nil {
+			c.c.fail("", false, &Page{URL: link, Depth: depth, Meta: meta}, "", -1, err)
+		}
 	}
-	wg.Wait()
+
+	c.Close()
+
+	return c.Wait()
 }
 
-func (cr *Crawler) handle(ctx context.Context, initialDepth, depth int, link, referer string) {
-	req, err := cr.newRequest(ctx, link, referer)
+func (cr *crawl) handle(ctx context.Context, seed *url.URL, initialDepth int, page *Page) {
+	var (
+		link  = page.URL
+		depth = page.Depth
+	)
+
+	info := &requestInfo{priority: seedPriority - page.Level}
+
+	req, err := cr.newRequest(withRequestInfo(ctx, info), link, page.Referer())
 	if err != nil {
-		cr.yieldError(link, "", -1, err)
+		cr.fail("", false, page, "", -1, err)
+		return
+	}
+
+	host := req.URL.Host
+
+	if !cr.dedup(req.URL.String()) {
+		return
+	}
+
+	if !cr.hostBudgets.admit(host, page.Level) || !cr.budget.admit() {
 		return
 	}
 
+	cr.setValidators(req, link)
+
 	resp, err := cr.request(req)
+	cr.stats.wait(host, info.waited)
 	if err != nil {
-		cr.yieldError(link, "", -1, err)
+		if ctx.Err() == nil {
+			//> Requests aborted with the crawl are not failures of hosts
+			cr.budget.done(true)
+		}
+		cr.fail(host, info.sent, page, "", -1, err)
 		return
 	}
 	defer resp.Body.Close()
 
+	cr.stats.response(host, resp.StatusCode)
+
+	if resp.StatusCode == http.StatusNotModified && cr.ops.State != nil {
+		cr.budget.done(false)
+		cr.pageNotModified(page, resp.Header)
+		return
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		cr.yieldError(link, "", -1, errors.New(fmt.Sprintf("bad response status: %d", resp.StatusCode)))
+		cr.budget.done(true)
+		cr.fail(host, false, page, "", -1, &StatusError{StatusCode: resp.StatusCode})
 		return
 	}
 
-	contentType := resp.Header.Get("Content-Type")
+	cr.budget.done(false)
 
-	if strings.HasPrefix(contentType, "text/html") {
-		var wg sync.WaitGroup
-		defer wg.Wait()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer resp.Body.Close() //> Before waiting for the links, which need the slots the response holds
 
-		if err := cr.filter(ctx, resp.Body, func(pos int, title string) error {
+	handler := cr.contentHandler(resp.Header.Get("Content-Type"))
+	if handler == nil {
+		//> Don't need to parse, nor to download the rest to remember the state
+		return
+	}
 
-			cr.yieldTitle(depth, pos, link, title)
+	var (
+		limited = cr.stats.reader(host, cr.budget.reader(cr.hostBudgets.reader(host, resp.Body)))
+		body    = limited
+	)
+	if cr.ops.State != nil {
+		sum := sha256.New()
+		body = io.TeeReader(limited, sum)
+
+		defer func() {
+			//> Handler is not obliged to read the whole body, but the rest of a large one is not worth downloading just to hash it
+			if n, err := io.Copy(sum, io.LimitReader(limited, maxStateTail+1)); err != nil || n > maxStateTail {
+				return
+			}
+			cr.pageFetched(page, resp.Header, hex.EncodeToString(sum.Sum(nil)))
+		}()
+	}
 
-			return nil
+	if err := handler.HandleContent(ctx, body, func(pos int, title string) error {
 
-		}, func(pos int, crawledLink string) error {
+		cr.yieldTitle(page, pos, title)
 
-			originURL, err := url.Parse(link)
-			if err != nil {
-				cr.yieldError(link, crawledLink, -1, err)
-				return err
-			}
+		return nil
 
-			crawledURL, err := url.Parse(crawledLink)
-			if err != nil {
-				cr.yieldError(link, crawledLink, pos, err)
-				return err
-			}
+	}, func(pos int, found Link) error {
+		crawledLink := found.Href
 
+		if cr.budget.stopped() {
+			return nil
+		}
+
+		originURL, err := url.Parse(link)
+		if err != nil {
+			cr.fail(host, false, page, crawledLink, -1, err)
+			return err
+		}
+
+		crawledURL, err := url.Parse(crawledLink)
+		if err != nil {
+			cr.fail(host, false, page, crawledLink, pos, err)
+			return err
+		}
 
-			absURL := originURL.ResolveReference(crawledURL)
+		cr.stats.link(host)
 
-			external := crawledURL.Host != "" && crawledURL.Host != originURL.Host
+		absURL := cr.ops.URLNormalizer.Normalize(originURL.ResolveReference(crawledURL))
 
-			doWeNeedThisLink := cr.yieldURL(depth, pos, link, crawledLink, &(*absURL), external)
+		if !cr.inScope(seed, absURL) {
+			return nil
+		}
 
-			absURL.Fragment = ""
+		doWeNeedThisLink := cr.yieldURL(page, pos, found, &(*absURL), cr.relation(originURL, absURL))
 
-			if doWeNeedThisLink {
+		absURL.Fragment = ""
 
-				if (absURL.Scheme == "" || absURL.Scheme == "http" || absURL.Scheme == "https") &&
-					((initialDepth == 0 && cr.ops.Depth == 0) || (initialDepth != 0 && depth > 1)) {
-					wg.Add(1)
-					go func() {
-						defer wg.Done()
+		if doWeNeedThisLink {
 
-						cr.handle(ctx, initialDepth, depth-1, absURL.String(), link)
-					}()
+			if (absURL.Scheme == "" || absURL.Scheme == "http" || absURL.Scheme == "https") &&
+				((initialDepth == 0 && cr.ops.Depth == 0) || (initialDepth != 0 && depth > 1)) {
+				if cr.ops.PrefetchDNS {
+					cr.resolver.prefetch(absURL.Hostname())
 				}
 
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					cr.handle(ctx, seed, initialDepth, &Page{
+						URL:    absURL.String(),
+						Depth:  depth - 1,
+						Meta:   page.Meta,
+						Level:  page.Level + 1,
+						Path:   append(page.Path[:len(page.Path):len(page.Path)], link),
+						Anchor: found,
+					})
+				}()
 			}
 
-			return nil
-		}); err != nil {
-			return
 		}
 
-	} else if contentType == "" {
-		// TODO Try to detect CT automatically
-		// http.DetectContentType()
-	} else {
-		//> Don't need to parse
+		return nil
+	}); err != nil {
+		return
 	}
 }
 
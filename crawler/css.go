@@ -0,0 +1,49 @@
+package crawler
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"regexp"
+)
+
+var (
+	cssCommentRx = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	cssLinkRx    = regexp.MustCompile(`(?i)url\(\s*(?:"([^"]*)"|'([^']*)'|([^)'"\s]*))\s*\)|@import\s+(?:"([^"]*)"|'([^']*)')`)
+)
+
+// CSSFilter extracts url() references and @import rules from stylesheets
+func CSSFilter() FilterFunc {
+	return func(ctx context.Context, r io.Reader, yieldTitle func(pos int, title string) error, yieldLink func(pos int, link Link) error) error {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		//> Keep positions intact by blanking comments out instead of removing them
+		data = cssCommentRx.ReplaceAllFunc(data, func(comment []byte) []byte {
+			blank := make([]byte, len(comment))
+			for i := range blank {
+				blank[i] = ' '
+			}
+			return blank
+		})
+
+		str := string(data)
+
+		for _, match := range cssLinkRx.FindAllStringSubmatchIndex(str, -1) {
+			for i := 2; i < len(match); i += 2 {
+				if match[i] < 0 || match[i] == match[i+1] {
+					continue
+				}
+
+				if err := yieldLink(match[i], Link{Href: str[match[i]:match[i+1]]}); err != nil {
+					return err
+				}
+				break
+			}
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,143 @@
+package crawler
+
+import (
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HostLimits is a crawl budget of a single host. Zero fields mean no limit
+type HostLimits struct {
+	// Pages requested from the host
+	MaxPages int
+
+	// Bytes of response bodies read from the host; the page exceeding the budget is truncated
+	MaxBytes int64
+
+	// Link hops from the seed to a page of the host
+	MaxDepth int
+
+	// Time since the first request to the host
+	MaxDuration time.Duration
+
+	// Requests to the host in flight; zero for Options.ParallelRequestsPerHost
+	ParallelRequests int
+}
+
+// HostLimitsRule overrides default HostLimits for hosts matching the pattern
+type HostLimitsRule struct {
+	// Host glob in path.Match syntax, e.g. "en.wikipedia.org" or "*.wikipedia.org"
+	Pattern string
+
+	Limits HostLimits
+}
+
+var errHostBytesLimit = errors.New("host bytes limit exceeded")
+
+type hostUsage struct {
+	limits HostLimits
+
+	started time.Time
+	pages   int
+	bytes   int64
+}
+
+type hostBudgets struct {
+	defaults  HostLimits
+	overrides []HostLimitsRule
+
+	lock  sync.Mutex
+	hosts map[string]*hostUsage
+}
+
+func newHostBudgets(defaults HostLimits, overrides []HostLimitsRule) *hostBudgets {
+	return &hostBudgets{
+		defaults:  defaults,
+		overrides: overrides,
+		hosts:     map[string]*hostUsage{},
+	}
+}
+
+func (hb *hostBudgets) limits(host string) HostLimits {
+	return hostLimits(hb.defaults, hb.overrides, host)
+}
+
+func hostLimits(defaults HostLimits, overrides []HostLimitsRule, host string) HostLimits {
+	host = strings.ToLower(host)
+	for _, rule := range overrides {
+		if ok, _ := path.Match(strings.ToLower(rule.Pattern), host); ok {
+			return rule.Limits
+		}
+	}
+	return defaults
+}
+
+func (hb *hostBudgets) usage(host string) *hostUsage {
+	u, ok := hb.hosts[host]
+	if !ok {
+		u = &hostUsage{limits: hb.limits(host)}
+		hb.hosts[host] = u
+	}
+	return u
+}
+
+// admit reserves a page of the host's budget; false if the budget is exhausted
+func (hb *hostBudgets) admit(host string, level int) bool {
+	hb.lock.Lock()
+	defer hb.lock.Unlock()
+
+	u := hb.usage(host)
+	l := u.limits
+
+	if u.started.IsZero() {
+		u.started = time.Now()
+	}
+
+	if (l.MaxPages > 0 && u.pages >= l.MaxPages) ||
+		(l.MaxBytes > 0 && u.bytes >= l.MaxBytes) ||
+		(l.MaxDepth > 0 && level > l.MaxDepth) ||
+		(l.MaxDuration > 0 && time.Since(u.started) > l.MaxDuration) {
+		return false
+	}
+
+	u.pages++
+	return true
+}
+
+// reader counts bytes read from the host and fails once the host's byte budget is exceeded
+func (hb *hostBudgets) reader(host string, r io.Reader) io.Reader {
+	return &hostBudgetReader{hb: hb, host: host, r: r}
+}
+
+type hostBudgetReader struct {
+	hb   *hostBudgets
+	host string
+	r    io.Reader
+}
+
+func (r *hostBudgetReader) Read(p []byte) (int, error) {
+	r.hb.lock.Lock()
+	u := r.hb.usage(r.host)
+	if u.limits.MaxBytes > 0 {
+		left := u.limits.MaxBytes - u.bytes
+		if left <= 0 {
+			r.hb.lock.Unlock()
+			return 0, errHostBytesLimit
+		}
+		if int64(len(p)) > left {
+			p = p[:left]
+		}
+	}
+	r.hb.lock.Unlock()
+
+	n, err := r.r.Read(p)
+
+	r.hb.lock.Lock()
+	u.bytes += int64(n)
+	r.hb.lock.Unlock()
+
+	return n, err
+}
@@ -0,0 +1,38 @@
+package crawler
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestHostBudgets(t *testing.T) {
+	hb := newHostBudgets(HostLimits{MaxPages: 2}, []HostLimitsRule{
+		{Pattern: "*.wikipedia.org", Limits: HostLimits{MaxDepth: 1, MaxBytes: 10}},
+	})
+
+	var admitted []bool
+	for _, host := range []string{"example.com", "example.com", "example.com", "other.com"} {
+		admitted = append(admitted, hb.admit(host, 5))
+	}
+	if !(admitted[0] && admitted[1] && !admitted[2] && admitted[3]) {
+		t.Log("bad page limit; actual", admitted)
+		t.Fail()
+	}
+
+	if hb.admit("en.wikipedia.org", 2) || !hb.admit("en.wikipedia.org", 1) {
+		t.Log("bad depth limit")
+		t.Fail()
+	}
+
+	data, err := ioutil.ReadAll(hb.reader("en.wikipedia.org", strings.NewReader("0123456789abcdef")))
+	if err != errHostBytesLimit || string(data) != "0123456789" {
+		t.Log("bad bytes limit; actual", string(data), err)
+		t.Fail()
+	}
+
+	if hb.admit("en.wikipedia.org", 0) {
+		t.Log("host with exhausted bytes budget is admitted")
+		t.Fail()
+	}
+}
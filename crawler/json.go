@@ -0,0 +1,72 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// JSONFilter extracts absolute http(s) URLs found in string values of JSON documents
+func JSONFilter() FilterFunc {
+	return func(ctx context.Context, r io.Reader, yieldTitle func(pos int, title string) error, yieldLink func(pos int, link Link) error) error {
+		type frame struct {
+			object    bool
+			expectKey bool
+		}
+
+		var (
+			dec   = json.NewDecoder(r)
+			stack []frame
+		)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+
+			pos := int(dec.InputOffset())
+
+			tok, err := dec.Token()
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+			var top *frame
+			if len(stack) > 0 {
+				top = &stack[len(stack)-1]
+			}
+
+			if delim, ok := tok.(json.Delim); ok {
+				switch delim {
+				case '{', '[':
+					if top != nil && top.object {
+						top.expectKey = true //> After the nested value is over
+					}
+					stack = append(stack, frame{object: delim == '{', expectKey: delim == '{'})
+				case '}', ']':
+					stack = stack[:len(stack)-1]
+				}
+				continue
+			}
+
+			isKey := false
+			if top != nil && top.object {
+				isKey = top.expectKey
+				top.expectKey = !top.expectKey
+			}
+
+			if link, ok := tok.(string); ok && !isKey {
+				if strings.HasPrefix(link, "http://") || strings.HasPrefix(link, "https://") {
+					if err := yieldLink(pos, Link{Href: link}); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+)
+
+var (
+	pdfObjRx   = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	pdfInfoRx  = regexp.MustCompile(`/Info\s+(\d+)\s+\d+\s+R`)
+	pdfURIRx   = regexp.MustCompile(`/URI\s*[(<]`)
+	pdfTitleRx = regexp.MustCompile(`/Title\s*[(<]`)
+	pdfIntRx   = regexp.MustCompile(`/(N|First)\s+(\d+)`)
+
+	pdfStreamRx = regexp.MustCompile(`>>\s*stream(?:\r\n|\n|\r)?`)
+)
+
+type pdfObject struct {
+	pos  int
+	body []byte
+}
+
+// PDFFilter extracts document title from the document information dictionary and URI link annotations of PDF files.
+// Only the first maxSize bytes of a document are read; zero for no limit.
+// Encrypted documents yield nothing.
+func PDFFilter(maxSize int64) FilterFunc {
+	return func(ctx context.Context, r io.Reader, yieldTitle func(pos int, title string) error, yieldLink func(pos int, link Link) error) error {
+		if maxSize > 0 {
+			r = io.LimitReader(r, maxSize)
+		}
+
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		if !bytes.HasPrefix(data, []byte("%PDF-")) || bytes.Contains(data, []byte("/Encrypt")) {
+			return nil
+		}
+
+		objects := parsePDFObjects(data, maxSize)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		if m := pdfInfoRx.FindAllSubmatch(data, -1); len(m) > 0 {
+			//> Incremental updates append new trailers, the last one wins
+			if info, ok := objects[string(m[len(m)-1][1])]; ok {
+				if loc := pdfTitleRx.FindIndex(info.body); loc != nil {
+					if title, ok := parsePDFString(info.body, loc[1]-1); ok && len(title) > 0 {
+						if err := yieldTitle(info.pos, decodePDFText(title)); err != nil {
+							return err
+						}
+					}
+				}
+			}
+		}
+
+		for _, obj := range sortedPDFObjects(objects) {
+			for _, loc := range pdfURIRx.FindAllIndex(obj.body, -1) {
+				if uri, ok := parsePDFString(obj.body, loc[1]-1); ok && len(uri) > 0 {
+					if err := yieldLink(obj.pos, Link{Href: string(uri)}); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		return nil
+	}
+}
+
+// parsePDFObjects finds all "N G obj ... endobj" objects including ones packed into compressed object streams.
+// Content of regular streams is dropped.
+func parsePDFObjects(data []byte, maxSize int64) map[string]pdfObject {
+	objects := map[string]pdfObject{}
+
+	for _, loc := range pdfObjRx.FindAllSubmatchIndex(data, -1) {
+		num := string(data[loc[2]:loc[3]])
+
+		body := data[loc[1]:]
+		if end := bytes.Index(body, []byte("endobj")); end >= 0 {
+			body = body[:end]
+		}
+
+		var stream []byte
+		if loc := pdfStreamRx.FindIndex(body); loc != nil {
+			stream = body[loc[1]:]
+			if end := bytes.LastIndex(stream, []byte("endstream")); end >= 0 {
+				stream = stream[:end]
+			}
+			body = body[:loc[0]+2]
+		}
+
+		objects[num] = pdfObject{pos: loc[0], body: body}
+
+		if stream != nil && bytes.Contains(body, []byte("/ObjStm")) && bytes.Contains(body, []byte("/FlateDecode")) {
+			parsePDFObjectStream(loc[0], body, stream, maxSize, objects)
+		}
+	}
+
+	return objects
+}
+
+func parsePDFObjectStream(pos int, dict, stream []byte, maxSize int64, objects map[string]pdfObject) {
+	var n, first int
+	for _, m := range pdfIntRx.FindAllSubmatch(dict, -1) {
+		v, _ := strconv.Atoi(string(m[2]))
+		if string(m[1]) == "N" {
+			n = v
+		} else {
+			first = v
+		}
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(stream))
+	if err != nil {
+		return
+	}
+	defer zr.Close()
+
+	var r io.Reader = zr
+	if maxSize > 0 {
+		r = io.LimitReader(r, maxSize)
+	}
+
+	//> Streams are often truncated by a byte or two, so take whatever was inflated
+	data, _ := ioutil.ReadAll(r)
+
+	if first < 0 || first > len(data) {
+		return
+	}
+
+	header := bytes.Fields(data[:first])
+	if len(header) > 2*n {
+		header = header[:2*n]
+	}
+
+	for i := 0; i+1 < len(header); i += 2 {
+		//> Offsets come from the document, so may be anything
+		offset, err := strconv.Atoi(string(header[i+1]))
+		if err != nil || offset < 0 || offset > len(data)-first {
+			continue
+		}
+
+		end := len(data)
+		if i+3 < len(header) {
+			if next, err := strconv.Atoi(string(header[i+3])); err == nil && next >= offset && next <= len(data)-first {
+				end = first + next
+			}
+		}
+
+		objects[string(header[i])] = pdfObject{pos: pos, body: data[first+offset : end]}
+	}
+}
+
+func sortedPDFObjects(objects map[string]pdfObject) []pdfObject {
+	sorted := make([]pdfObject, 0, len(objects))
+	for _, obj := range objects {
+		sorted = append(sorted, obj)
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].pos < sorted[j].pos
+	})
+
+	return sorted
+}
+
+// parsePDFString parses literal "(...)" or hexadecimal "<...>" string starting at data[i]
+func parsePDFString(data []byte, i int) ([]byte, bool) {
+	if i >= len(data) {
+		return nil, false
+	}
+
+	if data[i] == '<' {
+		end := bytes.IndexByte(data[i:], '>')
+		if end < 0 {
+			return nil, false
+		}
+
+		digits := make([]byte, 0, end)
+		for _, c := range data[i+1 : i+end] {
+			if ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F') {
+				digits = append(digits, c)
+			}
+		}
+		if len(digits)%2 == 1 {
+			digits = append(digits, '0')
+		}
+
+		s, err := hex.DecodeString(string(digits))
+		return s, err == nil
+	}
+
+	var (
+		s     []byte
+		depth = 0
+	)
+
+	for i++; i < len(data); i++ {
+		c := data[i]
+
+		switch {
+		case c == '\\' && i+1 < len(data):
+			i++
+			switch e := data[i]; e {
+			case 'n':
+				s = append(s, '\n')
+			case 'r':
+				s = append(s, '\r')
+			case 't':
+				s = append(s, '\t')
+			case 'b':
+				s = append(s, '\b')
+			case 'f':
+				s = append(s, '\f')
+			case '\r', '\n':
+				//> Line continuation
+				if e == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+					i++
+				}
+			default:
+				if '0' <= e && e <= '7' {
+					v := 0
+					for n := 0; n < 3 && i < len(data) && '0' <= data[i] && data[i] <= '7'; n++ {
+						v = v*8 + int(data[i]-'0')
+						i++
+					}
+					i--
+					s = append(s, byte(v))
+				} else {
+					s = append(s, e)
+				}
+			}
+		case c == '(':
+			depth++
+			s = append(s, c)
+		case c == ')':
+			if depth == 0 {
+				return s, true
+			}
+			depth--
+			s = append(s, c)
+		default:
+			s = append(s, c)
+		}
+	}
+
+	return nil, false
+}
+
+// decodePDFText decodes UTF-16BE text strings marked with BOM; anything else is treated as PDFDocEncoding,
+// which is close enough to Latin-1
+func decodePDFText(s []byte) string {
+	if len(s) >= 2 && s[0] == 0xfe && s[1] == 0xff {
+		u := make([]uint16, 0, len(s)/2)
+		for i := 2; i+1 < len(s); i += 2 {
+			u = append(u, uint16(s[i])<<8|uint16(s[i+1]))
+		}
+		return string(utf16.Decode(u))
+	}
+
+	r := make([]rune, len(s))
+	for i, c := range s {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
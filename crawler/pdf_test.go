@@ -0,0 +1,65 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestPDFFilter(t *testing.T) {
+	packed := []string{
+		"<< /Type /Annot /Subtype /Link /A << /S /URI /URI (https://example.com/packed) >> >>\n",
+		"<< /Title (Outline item) >>",
+	}
+	header := fmt.Sprintf("5 0 6 %d\n", len(packed[0]))
+
+	var objStm bytes.Buffer
+	w := zlib.NewWriter(&objStm)
+	_, _ = w.Write([]byte(header + packed[0] + packed[1]))
+	_ = w.Close()
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.5\n")
+	pdf.WriteString("1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	pdf.WriteString("3 0 obj\n<< /Type /Annot /Subtype /Link /A << /S /URI /URI (https://example.com/a\\(1\\)) >> >>\nendobj\n")
+	pdf.WriteString("4 0 obj\n<< /Title <FEFF0054006900740065006C> /Author (Nobody) >>\nendobj\n")
+	pdf.WriteString(fmt.Sprintf("7 0 obj\n<< /Type /ObjStm /N 2 /First %d /Filter /FlateDecode /Length %d >>\nstream\n", len(header), objStm.Len()))
+	pdf.Write(objStm.Bytes())
+	pdf.WriteString("\nendstream\nendobj\n")
+	pdf.WriteString("trailer\n<< /Root 1 0 R /Info 4 0 R >>\n%%EOF\n")
+
+	titles, links := collectFilter(t, PDFFilter(0), pdf.String())
+
+	if expected := []string{"Titel"}; !reflect.DeepEqual(titles, expected) {
+		t.Log("bad titles; actual", titles, "expected", expected)
+		t.Fail()
+	}
+	if expected := []string{"https://example.com/a(1)", "https://example.com/packed"}; !reflect.DeepEqual(links, expected) {
+		t.Log("bad links; actual", links, "expected", expected)
+		t.Fail()
+	}
+
+	titles, links = collectFilter(t, PDFFilter(64), pdf.String())
+	if len(titles) != 0 || len(links) != 0 {
+		t.Log("size cap is ignored; actual", titles, links)
+		t.Fail()
+	}
+
+	//> Hostile offsets of packed objects
+	for _, header := range []string{"5 -50 6 0\n", "5 10 6 -50\n", "5 0 6 9223372036854775807\n", "5 9223372036854775807\n"} {
+		objStm.Reset()
+		w := zlib.NewWriter(&objStm)
+		_, _ = w.Write([]byte(header + packed[0]))
+		_ = w.Close()
+
+		var pdf bytes.Buffer
+		pdf.WriteString("%PDF-1.5\n")
+		pdf.WriteString(fmt.Sprintf("7 0 obj\n<< /Type /ObjStm /N 2 /First %d /Filter /FlateDecode /Length %d >>\nstream\n", len(header), objStm.Len()))
+		pdf.Write(objStm.Bytes())
+		pdf.WriteString("\nendstream\nendobj\n%%EOF\n")
+
+		collectFilter(t, PDFFilter(0), pdf.String())
+	}
+}
@@ -0,0 +1,99 @@
+package crawler
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Recrawler periodically revisits pages known to the state store.
+// Pages which change often are revisited often, pages which never change are revisited less and less frequently.
+type Recrawler struct {
+	Crawler *Crawler
+
+	// Must be the same store the crawler uses
+	Store StateStore
+
+	// Bounds of revisit interval
+	MinInterval time.Duration
+	MaxInterval time.Duration
+
+	// Depth revisits are fed with; 1 to refetch known pages only
+	Depth int
+
+	// Optional; called with the result of every revisit round and the error Feed returned, see Feed
+	YieldRound func(result *CrawlResult, err error)
+}
+
+// NextVisit estimates when the page is worth to be revisited.
+// Interval is the average time between observed changes; if page never changed since the first visit,
+// it is the time since the first visit, which makes revisits back off exponentially.
+func NextVisit(state PageState, minInterval, maxInterval time.Duration) time.Time {
+	span := state.Checked.Sub(state.First)
+
+	interval := span
+	if state.Changes > 1 {
+		interval = span / time.Duration(state.Changes-1)
+	}
+
+	if interval < minInterval {
+		interval = minInterval
+	}
+	if maxInterval > 0 && interval > maxInterval {
+		interval = maxInterval
+	}
+
+	return state.Checked.Add(interval)
+}
+
+// Due returns pages which should be revisited by now, the most overdue first
+func (rc *Recrawler) Due(now time.Time) []string {
+	type due struct {
+		link string
+		at   time.Time
+	}
+
+	var pages []due
+	rc.Store.Range(func(link string, state PageState) bool {
+		if at := NextVisit(state, rc.MinInterval, rc.MaxInterval); !at.After(now) {
+			pages = append(pages, due{link: link, at: at})
+		}
+		return true
+	})
+
+	sort.Slice(pages, func(i, j int) bool {
+		return pages[i].at.Before(pages[j].at)
+	})
+
+	links := make([]string, len(pages))
+	for i, page := range pages {
+		links[i] = page.link
+	}
+	return links
+}
+
+// Run feeds due pages to the crawler every tick until the context is done; results of rounds are passed to YieldRound
+func (rc *Recrawler) Run(ctx context.Context, tick time.Duration) error {
+	depth := rc.Depth
+	if depth == 0 {
+		depth = 1
+	}
+
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for {
+		if links := rc.Due(time.Now()); len(links) > 0 {
+			result, err := rc.Crawler.Feed(ctx, depth, links...)
+			if rc.YieldRound != nil {
+				rc.YieldRound(result, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package crawler
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Relation of a discovered link to the page it was found on
+type Relation int
+
+const (
+	// Same host and port, relative links included
+	SameHost Relation = iota
+
+	// Different host of the same registrable domain (eTLD+1), e.g. www.example.com and blog.example.com
+	SameDomain
+
+	// Different registrable domain
+	External
+)
+
+func (rel Relation) String() string {
+	switch rel {
+	case SameHost:
+		return "same-host"
+	case SameDomain:
+		return "same-domain"
+	case External:
+		return "external"
+	default:
+		return "unknown"
+	}
+}
+
+func hostPort(u *url.URL) (string, string) {
+	host, port := strings.TrimSuffix(strings.ToLower(u.Hostname()), "."), u.Port()
+	if port == "" {
+		port = defaultPorts[strings.ToLower(u.Scheme)]
+	}
+	return host, port
+}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+func (cr *Crawler) relation(from, to *url.URL) Relation {
+	if to.Host == "" {
+		return SameHost
+	}
+
+	fromHost, fromPort := hostPort(from)
+	toHost, toPort := hostPort(to)
+
+	if fromHost == toHost && fromPort == toPort {
+		return SameHost
+	}
+
+	fromDomain, err := cr.ops.PublicSuffixList.RegistrableDomain(fromHost)
+	if err != nil {
+		fromDomain = fromHost
+	}
+	toDomain, err := cr.ops.PublicSuffixList.RegistrableDomain(toHost)
+	if err != nil {
+		toDomain = toHost
+	}
+
+	if fromDomain == toDomain {
+		return SameDomain
+	}
+
+	return External
+}
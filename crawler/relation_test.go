@@ -0,0 +1,32 @@
+package crawler
+
+import (
+	"github.com/themakers/simple-crawler/psl"
+	"net/url"
+	"testing"
+)
+
+func TestRelation(t *testing.T) {
+	cr := &Crawler{ops: Options{PublicSuffixList: psl.Default()}}
+
+	for _, c := range []struct {
+		from, to string
+		expected Relation
+	}{
+		{"https://www.example.com/a", "/b", SameHost},
+		{"https://example.com:443/", "https://example.com/", SameHost},
+		{"https://Example.com/", "http://example.com./", SameDomain},
+		{"https://www.example.com/", "https://blog.example.com/", SameDomain},
+		{"https://example.com/", "https://example.com:8443/", SameDomain},
+		{"https://alice.github.io/", "https://bob.github.io/", External},
+		{"https://example.com/", "https://example.org/", External},
+	} {
+		from, _ := url.Parse(c.from)
+		to, _ := url.Parse(c.to)
+
+		if actual := cr.relation(from, from.ResolveReference(to)); actual != c.expected {
+			t.Log("bad relation of", c.from, "and", c.to, "; actual", actual, "expected", c.expected)
+			t.Fail()
+		}
+	}
+}
@@ -1,20 +1,218 @@
 package crawler
 
 import (
+	"context"
 	"github.com/themakers/simple-crawler/keyed_pool"
+	"io"
+	"math"
+	"net"
 	"net/http"
+	"sync"
+	"time"
 )
 
-func newRequestPool(client *http.Client, requestsPerKey int) func(q *http.Request) (resp *http.Response, err error) {
-	wait := keyed_pool.Waiter(requestsPerKey)
+// Requests waiting for the same host are sent in order of priority
+const (
+	//> robots.txt and sitemaps tell about many pages at once
+	sitemapPriority = 1
 
+	//> Pages get minus their level, so shallower pages go first
+	seedPriority = 0
+)
+
+// requestInfo is passed to the pool with the request context
+type requestInfo struct {
+	priority int
+
+	// Time the request waited for a slot of the host; set by the pool
+	waited time.Duration
+
+	// Set by the pool once the request is passed to the client, i.e. it's not aborted while waiting for a slot
+	sent bool
+}
+
+type requestInfoKey struct{}
+
+func withRequestInfo(ctx context.Context, info *requestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// PoolBy tells what requests in flight are limited by besides the host
+type PoolBy int
+
+const (
+	PoolByHost PoolBy = iota
+
+	// Server IP address, so virtual hosts of the same server share a limit
+	PoolByIP
+
+	// /24 subnet for IPv4 and /64 for IPv6, so shared hosting servers share a limit
+	PoolBySubnet
+)
+
+// addrPool limits requests in flight per server address
+type addrPool struct {
+	by       PoolBy
+	slots    *keyed_pool.Semaphore
+	resolver *resolver
+}
+
+// key is the address of the host to pool by; false if the host can't be resolved
+func (p *addrPool) key(ctx context.Context, host string) (string, bool) {
+	ips, err := p.resolver.lookup(ctx, host)
+	if err != nil || len(ips) == 0 {
+		//> The request is going to fail anyway
+		return "", false
+	}
+
+	ip := ips[0]
+
+	if p.by == PoolBySubnet {
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4.Mask(net.CIDRMask(24, 32)).String() + "/24", true
+		}
+		return ip.Mask(net.CIDRMask(64, 128)).String() + "/64", true
+	}
+
+	return ip.String(), true
+}
+
+// newRequestPool returns a func doing requests within the limits; the slots are held until the response body is closed
+func newRequestPool(client *http.Client, slots *keyed_pool.Semaphore, adaptive *keyed_pool.AIMD, addrs *addrPool) func(q *http.Request) (resp *http.Response, err error) {
 	return func(q *http.Request) (resp *http.Response, err error) {
-		key := q.Host
-		if key == "" {
-			key = q.URL.Host
+		key := requestKey(q)
+
+		if adaptive != nil {
+			adaptive.Start(key)
 		}
 
-		defer wait(key)()
-		return client.Do(q)
+		info, _ := q.Context().Value(requestInfoKey{}).(*requestInfo)
+		if info == nil {
+			info = &requestInfo{}
+		}
+
+		t0 := time.Now()
+		if err := slots.AcquirePriority(q.Context(), key, info.priority); err != nil {
+			info.waited = time.Since(t0)
+			return nil, &waitAbortedError{err: err}
+		}
+
+		release := func() {
+			slots.Release(key)
+		}
+
+		if addrs != nil {
+			addrKey, ok := addrs.key(q.Context(), q.URL.Hostname())
+			if !ok {
+				//> Still takes a total slot
+				addrKey = "host:" + key
+			}
+
+			if err := addrs.slots.AcquirePriority(q.Context(), addrKey, info.priority); err != nil {
+				info.waited = time.Since(t0)
+				release()
+				return nil, &waitAbortedError{err: err}
+			}
+
+			release = func() {
+				addrs.slots.Release(addrKey)
+				slots.Release(key)
+			}
+		}
+
+		info.waited = time.Since(t0)
+
+		t0 = time.Now()
+		info.sent = true
+		resp, err = client.Do(q)
+
+		if adaptive != nil {
+			switch {
+			case err != nil && q.Context().Err() != nil:
+				//> The crawl is aborted or out of time, which tells nothing about the host
+			case err != nil && ClassifyError(err) == ErrorTimeout,
+				err == nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable):
+				adaptive.Failure(key)
+			case err == nil:
+				//> Time to the response headers; body reading time depends on the size too much
+				adaptive.Success(key, time.Since(t0))
+			}
+		}
+
+		if err != nil {
+			release()
+			return nil, err
+		}
+
+		//> The connection is busy until the body is read
+		resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+
+		return resp, nil
+	}
+}
+
+// releasingBody releases slots of the request once closed
+type releasingBody struct {
+	io.ReadCloser
+
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// waitAbortedError is returned for requests whose context is done while they wait for a slot.
+// It's classified as ErrorCanceled whatever the context error is, since nothing was sent to the host
+type waitAbortedError struct {
+	err error
+}
+
+func (e *waitAbortedError) Error() string {
+	return "aborted waiting for a request slot: " + e.err.Error()
+}
+
+func (e *waitAbortedError) Unwrap() error {
+	return e.err
+}
+
+func (e *waitAbortedError) Is(target error) bool {
+	return target == context.Canceled
+}
+
+func requestKey(q *http.Request) string {
+	if q.Host != "" {
+		return q.Host
+	}
+	return q.URL.Host
+}
+
+// parallelRequests is the default limit of requests in flight to the host
+func (cr *Crawler) parallelRequests(host string) int {
+	if n := hostLimits(cr.ops.HostLimits, cr.ops.HostLimitsOverrides, host).ParallelRequests; n > 0 {
+		return n
+	}
+	if cr.ops.ParallelRequestsPerHost > 0 {
+		return cr.ops.ParallelRequestsPerHost
+	}
+	return math.MaxInt32
+}
+
+// HostContention returns n hosts with the most requests in flight and waiting, since the crawler was created.
+// With Options.PoolBy waits for servers and for MaxParallelRequests are not counted
+func (cr *Crawler) HostContention(n int) []keyed_pool.KeyStats {
+	return cr.hostSlots.Top(n)
+}
+
+// SetParallelRequests changes the limit of requests in flight to the host at runtime; zero restores the default one.
+// With Options.AdaptiveParallelRequests the limit is overridden again the next time the controller raises or lowers it
+func (cr *Crawler) SetParallelRequests(host string, n int) {
+	if n == 0 {
+		cr.hostSlots.ResetLimit(host)
+	} else {
+		cr.hostSlots.SetLimit(host, n)
 	}
 }
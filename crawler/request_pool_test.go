@@ -0,0 +1,177 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"github.com/themakers/simple-crawler/keyed_pool"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAddrPoolKey(t *testing.T) {
+	r := newResolver(staticResolver{"shared.test": {net.ParseIP("192.0.2.10")}}, 0, 0)
+
+	for _, c := range []struct {
+		by       PoolBy
+		host     string
+		expected string
+	}{
+		{PoolByIP, "192.0.2.10", "192.0.2.10"},
+		{PoolByIP, "shared.test", "192.0.2.10"},
+		{PoolBySubnet, "shared.test", "192.0.2.0/24"},
+		{PoolBySubnet, "2001:db8:1:2:3::4", "2001:db8:1:2::/64"},
+	} {
+		p := &addrPool{by: c.by, resolver: r}
+
+		if actual, ok := p.key(context.Background(), c.host); !ok || actual != c.expected {
+			t.Log("bad key of", c.host, "; actual", actual, "expected", c.expected)
+			t.Fail()
+		}
+	}
+}
+
+func TestRequestPoolHoldsSlotsUntilBodyClosed(t *testing.T) {
+	var inFlight, maxInFlight int64
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, q *http.Request) {
+		n := atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+
+		for {
+			max := atomic.LoadInt64(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt64(&maxInFlight, max, n) {
+				break
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		w.(http.Flusher).Flush()
+
+		//> Slow body after the headers are sent
+		time.Sleep(20 * time.Millisecond)
+
+		if q.URL.Path == "/" {
+			for i := 0; i < 20; i++ {
+				_, _ = fmt.Fprintf(w, "http://%s/%d\n", q.Host, i)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	cr := newTestCrawler(t, Options{
+		MaxParallelRequests:     2,
+		ParallelRequestsPerHost: 2,
+	})
+
+	result, err := cr.Feed(context.Background(), 2, srv.URL+"/")
+	if err != nil {
+		t.Log("unexpected error", err)
+		t.Fail()
+	}
+
+	if result.Pages != 21 {
+		t.Log("bad number of pages; actual", result.Pages, "expected", 21)
+		t.Fail()
+	}
+
+	if n := atomic.LoadInt64(&maxInFlight); n > 2 {
+		t.Log("too many responses in progress; actual", n, "expected", 2)
+		t.Fail()
+	}
+}
+
+func TestRequestPoolByIP(t *testing.T) {
+	var inFlight, maxInFlight int64
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, q *http.Request) {
+		n := atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+
+		for {
+			max := atomic.LoadInt64(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt64(&maxInFlight, max, n) {
+				break
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+
+		w.Header().Set("Content-Type", "text/html")
+		if q.URL.Path == "/" {
+			_, port, _ := net.SplitHostPort(q.Host)
+			for i := 0; i < 10; i++ {
+				_, _ = fmt.Fprintf(w, "http://a.test:%s/%d http://b.test:%s/%d\n", port, i, port, i)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	//> Virtual hosts of the same server
+	cr := newTestCrawler(t, Options{
+		Resolver: staticResolver{
+			"a.test": {net.ParseIP("127.0.0.1")},
+			"b.test": {net.ParseIP("127.0.0.1")},
+		},
+		ParallelRequestsPerHost: 10,
+		MaxParallelRequests:     10,
+		PoolBy:                  PoolByIP,
+		ParallelRequestsPerAddr: 2,
+	})
+
+	result, err := cr.Feed(context.Background(), 2, "http://a.test:"+port+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Pages != 21 {
+		t.Log("bad number of pages; actual", result.Pages, "expected", 21)
+		t.Fail()
+	}
+
+	if n := atomic.LoadInt64(&maxInFlight); n > 2 {
+		t.Log("virtual hosts don't share the limit; actual", n, "expected", 2)
+		t.Fail()
+	}
+}
+
+func TestRequestPoolAdaptiveDeadline(t *testing.T) {
+	done := make(chan struct{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, q *http.Request) {
+		<-done
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	key := srv.Listener.Addr().String()
+
+	slots := keyed_pool.NewSemaphore(8)
+	adaptive := keyed_pool.NewAIMD(slots, 1, func(key string) int { return 8 })
+	request := newRequestPool(http.DefaultClient, slots, adaptive, nil)
+
+	//> Up to 4 with 1+2+3 successes
+	adaptive.Start(key)
+	for i := 0; i < 6; i++ {
+		adaptive.Success(key, time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	q, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	if _, err := request(q); err == nil {
+		t.Fatal("no error of an aborted request")
+	}
+
+	//> Deadline of the crawl is not a timeout of the host
+	if limit := adaptive.Limit(key); limit != 4 {
+		t.Log("bad limit; actual", limit, "expected", 4)
+		t.Fail()
+	}
+}
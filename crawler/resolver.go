@@ -0,0 +1,338 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// Resolver looks up addresses of hosts.
+// Ttl tells how long the result may be cached; zero if unknown, then Options.ResolverTTL is used
+type Resolver interface {
+	LookupIP(ctx context.Context, host string) (ips []net.IP, ttl time.Duration, err error)
+}
+
+// SystemResolver resolves hosts with net.Resolver, which doesn't tell TTLs
+type SystemResolver struct {
+	// Nil for net.DefaultResolver
+	Resolver *net.Resolver
+}
+
+func (r SystemResolver) LookupIP(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	resolver := r.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ips := make([]net.IP, len(addrs))
+	for i, addr := range addrs {
+		ips[i] = addr.IP
+	}
+	return ips, 0, nil
+}
+
+// ResolveError is reported for hosts failed to resolve; see ErrorDNS
+type ResolveError struct {
+	Host string
+	Err  error
+}
+
+func (e *ResolveError) Error() string {
+	return "resolve " + e.Host + ": " + e.Err.Error()
+}
+
+func (e *ResolveError) Unwrap() error {
+	return e.Err
+}
+
+var errNoAddresses = errors.New("no addresses")
+
+const (
+	defaultResolverTTL         = 5 * time.Minute
+	defaultResolverNegativeTTL = 30 * time.Second
+
+	//> Lookups are shared by all the requests to the host, so are not bound to their contexts
+	resolveTimeout = 10 * time.Second
+
+	maxParallelPrefetches = 16
+
+	//> Expired entries are dropped this often, so the cache doesn't grow with every host ever crawled
+	resolverSweepInterval = time.Minute
+
+	//> Same as net.Dialer does
+	dialFallbackDelay = 300 * time.Millisecond
+	minDialTimeout    = 2 * time.Second
+)
+
+// resolver caches lookups of Resolver; concurrent lookups of the same host are done once
+type resolver struct {
+	r           Resolver
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	lock  sync.Mutex
+	cache map[string]*resolverEntry
+	swept time.Time
+
+	prefetches chan struct{}
+}
+
+type resolverEntry struct {
+	done    chan struct{} //> Closed once the lookup is done
+	ips     []net.IP
+	err     error
+	expires time.Time
+}
+
+func newResolver(r Resolver, ttl, negativeTTL time.Duration) *resolver {
+	if r == nil {
+		r = SystemResolver{}
+	}
+	if ttl == 0 {
+		ttl = defaultResolverTTL
+	}
+	if negativeTTL == 0 {
+		negativeTTL = defaultResolverNegativeTTL
+	}
+
+	return &resolver{
+		r:           r,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		cache:       map[string]*resolverEntry{},
+		swept:       time.Now(),
+		prefetches:  make(chan struct{}, maxParallelPrefetches),
+	}
+}
+
+// entry returns the cached entry of the host, starting a lookup if there is no fresh one
+func (r *resolver) entry(host string) *resolverEntry {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if time.Since(r.swept) > resolverSweepInterval {
+		r.sweep()
+	}
+
+	if e, ok := r.cache[host]; ok && !r.expired(e) {
+		return e
+	}
+
+	e := &resolverEntry{done: make(chan struct{})}
+	r.cache[host] = e
+
+	go r.resolve(host, e)
+
+	return e
+}
+
+// sweep drops expired entries; called with the lock held
+func (r *resolver) sweep() {
+	for host, e := range r.cache {
+		if r.expired(e) {
+			delete(r.cache, host)
+		}
+	}
+	r.swept = time.Now()
+}
+
+func (r *resolver) expired(e *resolverEntry) bool {
+	select {
+	case <-e.done:
+		return time.Now().After(e.expires)
+	default:
+		return false
+	}
+}
+
+func (r *resolver) resolve(host string, e *resolverEntry) {
+	defer close(e.done)
+
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+
+	ips, ttl, err := r.r.LookupIP(ctx, host)
+	if err == nil && len(ips) == 0 {
+		err = errNoAddresses
+	}
+
+	if err != nil {
+		e.err = &ResolveError{Host: host, Err: err}
+		e.expires = time.Now().Add(r.negativeTTL)
+		return
+	}
+
+	if ttl == 0 {
+		ttl = r.ttl
+	}
+
+	e.ips = ips
+	e.expires = time.Now().Add(ttl)
+}
+
+func (r *resolver) lookup(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+
+	e := r.entry(host)
+
+	select {
+	case <-e.done:
+		return e.ips, e.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// prefetch looks up the host in background; it's skipped if too many prefetches are in flight already
+func (r *resolver) prefetch(host string) {
+	if net.ParseIP(host) != nil {
+		return
+	}
+
+	select {
+	case r.prefetches <- struct{}{}:
+	default:
+		return
+	}
+
+	go func() {
+		defer func() { <-r.prefetches }()
+		<-r.entry(host).done
+	}()
+}
+
+// dial connects to addresses of the host. Addresses of the family of the first one are tried in turn,
+// and the other family is raced against them after a short delay, like net.Dialer does for hosts it resolves itself
+func (r *resolver) dial(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		ips, err := r.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		primaries, fallbacks := splitAddrFamilies(ips)
+		if len(fallbacks) == 0 {
+			return dialSerial(ctx, dialer, network, port, primaries)
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		type dialResult struct {
+			conn net.Conn
+			err  error
+		}
+
+		//> Buffered, so the loser doesn't block once the winner is returned
+		results := make(chan dialResult, 2)
+		pending := 0
+		start := func(ips []net.IP) {
+			pending++
+			go func() {
+				conn, err := dialSerial(ctx, dialer, network, port, ips)
+				results <- dialResult{conn, err}
+			}()
+		}
+
+		start(primaries)
+
+		fallback := time.NewTimer(dialFallbackDelay)
+		defer fallback.Stop()
+		fallbackStarted := false
+
+		var firstErr error
+		for {
+			select {
+			case <-fallback.C:
+				if !fallbackStarted {
+					fallbackStarted = true
+					start(fallbacks)
+				}
+
+			case res := <-results:
+				pending--
+
+				if res.err == nil {
+					if pending > 0 {
+						go func() {
+							if res := <-results; res.conn != nil {
+								_ = res.conn.Close()
+							}
+						}()
+					}
+					return res.conn, nil
+				}
+
+				if firstErr == nil {
+					firstErr = res.err
+				}
+
+				if !fallbackStarted {
+					fallbackStarted = true
+					start(fallbacks)
+				} else if pending == 0 {
+					return nil, firstErr
+				}
+			}
+		}
+	}
+}
+
+// splitAddrFamilies splits addresses into ones of the family of the first address and the rest
+func splitAddrFamilies(ips []net.IP) (primaries, fallbacks []net.IP) {
+	isV4 := ips[0].To4() != nil
+	for _, ip := range ips {
+		if (ip.To4() != nil) == isV4 {
+			primaries = append(primaries, ip)
+		} else {
+			fallbacks = append(fallbacks, ip)
+		}
+	}
+	return
+}
+
+// dialSerial tries addresses in turn; each one gets a share of the time left, so an unreachable address doesn't take all of it
+func dialSerial(ctx context.Context, dialer *net.Dialer, network, port string, ips []net.IP) (conn net.Conn, err error) {
+	var deadline time.Time
+	if dialer.Timeout > 0 {
+		deadline = time.Now().Add(dialer.Timeout)
+	}
+
+	for i, ip := range ips {
+		dialCtx := ctx
+		if !deadline.IsZero() {
+			left := time.Until(deadline)
+			timeout := left / time.Duration(len(ips)-i)
+			if timeout < minDialTimeout {
+				timeout = minDialTimeout
+				if timeout > left {
+					timeout = left
+				}
+			}
+
+			var cancel context.CancelFunc
+			dialCtx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		conn, err = dialer.DialContext(dialCtx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,193 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type staticResolver map[string][]net.IP
+
+func (r staticResolver) LookupIP(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	if ips, ok := r[host]; ok {
+		return ips, 0, nil
+	}
+	return nil, 0, errors.New("no such host")
+}
+
+type countingResolver struct {
+	lock    sync.Mutex
+	lookups map[string]int
+	ttl     time.Duration
+}
+
+func (r *countingResolver) LookupIP(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	r.lock.Lock()
+	r.lookups[host]++
+	r.lock.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	if host == "missing.test" {
+		return nil, 0, errors.New("no such host")
+	}
+	return []net.IP{net.ParseIP("127.0.0.1")}, r.ttl, nil
+}
+
+func TestResolverCache(t *testing.T) {
+	cr := &countingResolver{lookups: map[string]int{}, ttl: 20 * time.Millisecond}
+	r := newResolver(cr, time.Hour, time.Hour)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := r.lookup(context.Background(), "found.test"); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := r.lookup(context.Background(), "missing.test")
+
+			var resolveErr *ResolveError
+			if !errors.As(err, &resolveErr) || ClassifyError(err) != ErrorDNS {
+				t.Error("bad error", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	//> Concurrent lookups are done once, failures are cached too
+	if cr.lookups["found.test"] != 1 || cr.lookups["missing.test"] != 1 {
+		t.Log("bad number of lookups; actual", cr.lookups)
+		t.Fail()
+	}
+
+	//> TTL told by the resolver wins over the default one
+	time.Sleep(30 * time.Millisecond)
+
+	if _, err := r.lookup(context.Background(), "found.test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.lookup(context.Background(), "missing.test"); err == nil {
+		t.Fatal("no error of a missing host")
+	}
+
+	if cr.lookups["found.test"] != 2 || cr.lookups["missing.test"] != 1 {
+		t.Log("bad number of lookups after TTL; actual", cr.lookups)
+		t.Fail()
+	}
+}
+
+func TestResolverPrefetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, q *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if host, port, _ := net.SplitHostPort(q.Host); host == "seed.test" {
+			_, _ = fmt.Fprint(w, "http://a.test:"+port+"/ http://missing.test/")
+		}
+	}))
+	defer srv.Close()
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	resolver := &countingResolver{lookups: map[string]int{}}
+
+	var (
+		lock sync.Mutex
+		errs []error
+	)
+
+	cr := newTestCrawlerFunc(func(page *Page, link string, pos int, err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		errs = append(errs, err)
+	}, Options{
+		Resolver:    resolver,
+		PrefetchDNS: true,
+	})
+
+	result, err := cr.Feed(context.Background(), 2, "http://seed.test:"+port+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//> Hosts are resolved through the resolver by the default Client, once per host thanks to the cache
+	if result.Pages != 2 || resolver.lookups["seed.test"] != 1 || resolver.lookups["a.test"] != 1 {
+		t.Log("bad crawl of the seed; actual", result.Pages, resolver.lookups)
+		t.Fail()
+	}
+
+	if resolver.lookups["missing.test"] != 1 || len(errs) != 1 || ClassifyError(errs[0]) != ErrorDNS || result.Errors[ErrorDNS] != 1 {
+		t.Log("bad failed lookup; actual", resolver.lookups, errs, result.Errors)
+		t.Fail()
+	}
+}
+
+func TestResolverSweep(t *testing.T) {
+	cr := &countingResolver{lookups: map[string]int{}}
+	r := newResolver(cr, 10*time.Millisecond, 10*time.Millisecond)
+
+	for _, host := range []string{"a.test", "b.test", "missing.test"} {
+		_, _ = r.lookup(context.Background(), host)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	r.lock.Lock()
+	r.swept = time.Time{} //> Sweep on the next lookup
+	r.lock.Unlock()
+
+	_, _ = r.lookup(context.Background(), "c.test")
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.cache["c.test"]; len(r.cache) != 1 || !ok {
+		t.Log("expired entries are not dropped; actual", len(r.cache))
+		t.Fail()
+	}
+}
+
+func TestResolverDialFallback(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	//> The documentation prefix is not routed, so the IPv6 address is unreachable
+	r := newResolver(staticResolver{"dual.test": {net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"), net.ParseIP("127.0.0.1")}}, 0, 0)
+	dial := r.dial(&net.Dialer{Timeout: 10 * time.Second})
+
+	t0 := time.Now()
+	conn, err := dial(context.Background(), "tcp", net.JoinHostPort("dual.test", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	if time.Since(t0) > time.Second {
+		t.Log("fallback to IPv4 took", time.Since(t0))
+		t.Fail()
+	}
+}
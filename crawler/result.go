@@ -0,0 +1,248 @@
+package crawler
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ErrorClass string
+
+const (
+	ErrorDNS        ErrorClass = "dns"
+	ErrorTimeout    ErrorClass = "timeout"
+	ErrorTLS        ErrorClass = "tls"
+	ErrorConnection ErrorClass = "connection"
+	ErrorStatus     ErrorClass = "status"
+	ErrorURL        ErrorClass = "url"
+	ErrorCanceled   ErrorClass = "canceled"
+	ErrorOther      ErrorClass = "other"
+)
+
+// StatusError is reported for responses with status other than 200 and 304
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("bad response status: %d", e.StatusCode)
+}
+
+// ClassifyError tells what kind of failure err is
+func ClassifyError(err error) ErrorClass {
+	var (
+		statusErr *StatusError
+		dnsErr    *net.DNSError
+		resolvErr *ResolveError
+		netErr    net.Error
+		opErr     *net.OpError
+		certErr   x509.CertificateInvalidError
+		authErr   x509.UnknownAuthorityError
+		hostErr   x509.HostnameError
+		urlErr    *url.Error
+	)
+
+	switch {
+	case errors.As(err, &statusErr):
+		return ErrorStatus
+	case errors.Is(err, context.Canceled):
+		return ErrorCanceled
+	case errors.As(err, &resolvErr), errors.As(err, &dnsErr):
+		return ErrorDNS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTimeout
+	case errors.As(err, &certErr), errors.As(err, &authErr), errors.As(err, &hostErr),
+		strings.Contains(err.Error(), "tls: "):
+		return ErrorTLS
+	case errors.As(err, &opErr):
+		return ErrorConnection
+	case errors.As(err, &urlErr) && urlErr.Op == "parse":
+		return ErrorURL
+	default:
+		return ErrorOther
+	}
+}
+
+type HostStats struct {
+	// Requests done, failed ones included
+	Requests int
+
+	// Pages fetched successfully
+	Pages int
+
+	// Bytes of response bodies read
+	Bytes int64
+
+	// Links found on pages of the host
+	Links int
+
+	Errors int
+
+	// Time requests waited for a slot of the host, see Options.ParallelRequestsPerHost
+	WaitTime time.Duration
+}
+
+// CrawlResult summarizes a crawl
+type CrawlResult struct {
+	// Requests done, failed ones included
+	Requests int
+
+	// Pages fetched successfully, unchanged ones included
+	Pages int
+
+	// Pages responded with 304 Not Modified
+	Unchanged int
+
+	// Bytes of response bodies read
+	Bytes int64
+
+	// Links found by content handlers, before any filtering
+	Links int
+
+	// Time requests waited for slots of their hosts in total
+	WaitTime time.Duration
+
+	Errors      map[ErrorClass]int
+	StatusCodes map[int]int
+	Hosts       map[string]*HostStats
+
+	Duration time.Duration
+}
+
+type crawlStats struct {
+	lock    sync.Mutex
+	started time.Time
+	result  CrawlResult
+}
+
+func newCrawlStats() *crawlStats {
+	return &crawlStats{
+		started: time.Now(),
+		result: CrawlResult{
+			Errors:      map[ErrorClass]int{},
+			StatusCodes: map[int]int{},
+			Hosts:       map[string]*HostStats{},
+		},
+	}
+}
+
+func (s *crawlStats) host(host string) *HostStats {
+	h, ok := s.result.Hosts[host]
+	if !ok {
+		h = &HostStats{}
+		s.result.Hosts[host] = h
+	}
+	return h
+}
+
+func (s *crawlStats) response(host string, statusCode int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.result.Requests++
+	s.result.StatusCodes[statusCode]++
+	h := s.host(host)
+	h.Requests++
+
+	switch statusCode {
+	case 200:
+		s.result.Pages++
+		h.Pages++
+	case 304:
+		s.result.Pages++
+		s.result.Unchanged++
+		h.Pages++
+	}
+}
+
+func (s *crawlStats) error(host string, requested bool, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.result.Errors[ClassifyError(err)]++
+	if requested {
+		s.result.Requests++
+	}
+
+	//> Bad seeds and links fail before their host is known
+	if host == "" {
+		return
+	}
+
+	h := s.host(host)
+	h.Errors++
+	if requested {
+		h.Requests++
+	}
+}
+
+func (s *crawlStats) read(host string, n int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.result.Bytes += int64(n)
+	s.host(host).Bytes += int64(n)
+}
+
+func (s *crawlStats) wait(host string, d time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.result.WaitTime += d
+	s.host(host).WaitTime += d
+}
+
+func (s *crawlStats) link(host string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.result.Links++
+	s.host(host).Links++
+}
+
+func (s *crawlStats) snapshot() *CrawlResult {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	r := s.result
+	r.Duration = time.Since(s.started)
+
+	r.Errors = make(map[ErrorClass]int, len(s.result.Errors))
+	for class, n := range s.result.Errors {
+		r.Errors[class] = n
+	}
+	r.StatusCodes = make(map[int]int, len(s.result.StatusCodes))
+	for code, n := range s.result.StatusCodes {
+		r.StatusCodes[code] = n
+	}
+	r.Hosts = make(map[string]*HostStats, len(s.result.Hosts))
+	for host, h := range s.result.Hosts {
+		hc := *h
+		r.Hosts[host] = &hc
+	}
+
+	return &r
+}
+
+func (s *crawlStats) reader(host string, r io.Reader) io.Reader {
+	return &crawlStatsReader{s: s, host: host, r: r}
+}
+
+type crawlStatsReader struct {
+	s    *crawlStats
+	host string
+	r    io.Reader
+}
+
+func (r *crawlStatsReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.s.read(r.host, n)
+	return n, err
+}
@@ -0,0 +1,134 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClassifyError(t *testing.T) {
+	_, parseErr := url.Parse("http://[::1")
+
+	for _, c := range []struct {
+		err      error
+		expected ErrorClass
+	}{
+		{&StatusError{StatusCode: 404}, ErrorStatus},
+		{fmt.Errorf("get: %w", context.Canceled), ErrorCanceled},
+		{&url.Error{Op: "Get", URL: "http://x", Err: context.DeadlineExceeded}, ErrorTimeout},
+		{&url.Error{Op: "Get", URL: "http://x", Err: &net.DNSError{Err: "no such host", Name: "x", IsNotFound: true}}, ErrorDNS},
+		{&url.Error{Op: "Get", URL: "http://x", Err: &net.OpError{Op: "dial", Net: "tcp", Err: fmt.Errorf("connection refused")}}, ErrorConnection},
+		{&waitAbortedError{err: context.DeadlineExceeded}, ErrorCanceled},
+		{parseErr, ErrorURL},
+		{fmt.Errorf("something"), ErrorOther},
+	} {
+		if actual := ClassifyError(c.err); actual != c.expected {
+			t.Log("bad class of", c.err, "; actual", actual, "expected", c.expected)
+			t.Fail()
+		}
+	}
+}
+
+func TestCrawlResult(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, q *http.Request) {
+		switch q.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = fmt.Fprintf(w, "http://%s/a http://%s/missing", q.Host, q.Host)
+		case "/a":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = fmt.Fprint(w, "no links")
+		default:
+			http.NotFound(w, q)
+		}
+	}))
+	defer srv.Close()
+
+	var errs int
+	cr := newTestCrawlerFunc(func(page *Page, link string, pos int, err error) {
+		errs++
+	}, Options{})
+
+	result, err := cr.Feed(context.Background(), 2, srv.URL+"/")
+	if err != nil {
+		t.Log("unexpected error", err)
+		t.Fail()
+	}
+
+	host := srv.Listener.Addr().String()
+
+	if result.Requests != 3 || result.Pages != 2 || result.Links != 2 || errs != 1 {
+		t.Log("bad result; actual", result.Requests, result.Pages, result.Links, errs, "expected", 3, 2, 2, 1)
+		t.Fail()
+	}
+
+	if result.StatusCodes[200] != 2 || result.StatusCodes[404] != 1 || result.Errors[ErrorStatus] != 1 {
+		t.Log("bad status histogram; actual", result.StatusCodes, result.Errors)
+		t.Fail()
+	}
+
+	if h := result.Hosts[host]; h == nil || h.Requests != 3 || h.Bytes != result.Bytes || h.Bytes == 0 {
+		t.Log("bad host stats; actual", result.Hosts)
+		t.Fail()
+	}
+}
+
+func TestCrawlResultCanceled(t *testing.T) {
+	var served int64
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, q *http.Request) {
+		atomic.AddInt64(&served, 1)
+		time.Sleep(100 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/html")
+	}))
+	defer srv.Close()
+
+	cr := newTestCrawlerFunc(func(page *Page, link string, pos int, err error) {
+	}, Options{
+		ParallelRequestsPerHost: 1,
+	})
+
+	links := make([]string, 50)
+	for i := range links {
+		links[i] = fmt.Sprintf("%s/%d", srv.URL, i)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	result, _ := cr.Feed(ctx, 1, links...)
+
+	//> Requests waiting for the host are not sent, the one in flight may be
+	host := srv.Listener.Addr().String()
+	if n := int(atomic.LoadInt64(&served)); result.Requests > n+1 || result.Hosts[host].Requests != result.Requests {
+		t.Log("bad number of requests; actual", result.Requests, result.Hosts[host].Requests, "served", n)
+		t.Fail()
+	}
+
+	if result.Errors[ErrorCanceled] < 45 || result.Errors[ErrorTimeout] > 1 {
+		t.Log("bad errors; actual", result.Errors)
+		t.Fail()
+	}
+}
+
+func TestCrawlResultBadSeed(t *testing.T) {
+	cr := newTestCrawlerFunc(func(page *Page, link string, pos int, err error) {
+	}, Options{})
+
+	result, err := cr.Feed(context.Background(), 1, "::bad")
+	if err != nil {
+		t.Log("unexpected error", err)
+		t.Fail()
+	}
+
+	if len(result.Hosts) != 0 || result.Errors[ErrorURL] != 1 {
+		t.Log("bad result; actual", result.Hosts, result.Errors)
+		t.Fail()
+	}
+}
@@ -0,0 +1,98 @@
+package crawler
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// Feeds are quite often served as a generic XML
+var feedMediaTypes = []string{
+	"application/rss+xml",
+	"application/atom+xml",
+	"application/rdf+xml",
+	"application/xml",
+	"text/xml",
+}
+
+// RSSFilter extracts titles and links of RSS 0.9x/1.0/2.0 and Atom feeds and their items.
+// Any other XML document yields nothing.
+func RSSFilter() FilterFunc {
+	return func(ctx context.Context, r io.Reader, yieldTitle func(pos int, title string) error, yieldLink func(pos int, link Link) error) error {
+		dec := xml.NewDecoder(r)
+		dec.Strict = false
+
+		var parents []string
+
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+
+			pos := int(dec.InputOffset())
+
+			tok, err := dec.Token()
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+			switch tok := tok.(type) {
+			case xml.StartElement:
+				parent := ""
+				if len(parents) > 0 {
+					parent = parents[len(parents)-1]
+				}
+
+				isFeedElement := parent == "channel" || parent == "item" || parent == "feed" || parent == "entry"
+
+				if !isFeedElement || (tok.Name.Local != "title" && tok.Name.Local != "link") {
+					parents = append(parents, tok.Name.Local)
+					continue
+				}
+
+				var elem struct {
+					Href string `xml:"href,attr"`
+					Rel  string `xml:"rel,attr"`
+					Text string `xml:",chardata"`
+				}
+				if err := dec.DecodeElement(&elem, &tok); err != nil {
+					return err
+				}
+
+				if tok.Name.Local == "title" {
+					if title := strings.TrimSpace(elem.Text); title != "" {
+						if err := yieldTitle(pos, title); err != nil {
+							return err
+						}
+					}
+					continue
+				}
+
+				link := strings.TrimSpace(elem.Text)
+				if elem.Href != "" {
+					//> Atom's <link href="..."/>; self, edit, etc. are not links to the content
+					if elem.Rel != "" && elem.Rel != "alternate" {
+						continue
+					}
+					link = strings.TrimSpace(elem.Href)
+				}
+
+				if link != "" {
+					if err := yieldLink(pos, Link{Href: link, Rel: elem.Rel, Tag: tok.Name.Local}); err != nil {
+						return err
+					}
+				}
+
+			case xml.EndElement:
+				if len(parents) > 0 {
+					parents = parents[:len(parents)-1]
+				}
+			}
+		}
+	}
+}
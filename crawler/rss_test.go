@@ -0,0 +1,91 @@
+package crawler
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func collectFilter(t *testing.T, filter FilterFunc, data string) (titles, links []string) {
+	if err := filter(context.Background(), strings.NewReader(data), func(pos int, title string) error {
+		titles = append(titles, title)
+		return nil
+	}, func(pos int, link Link) error {
+		links = append(links, link.Href)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestRSSFilter(t *testing.T) {
+	titles, links := collectFilter(t, RSSFilter(), `<?xml version="1.0"?>
+<rss version="2.0">
+	<channel>
+		<title>News</title>
+		<link>https://example.com/</link>
+		<image><title>Logo</title><link>https://example.com/</link><url>https://example.com/logo.png</url></image>
+		<item>
+			<title><![CDATA[First & foremost]]></title>
+			<link>https://example.com/1</link>
+		</item>
+		<item>
+			<title>Second</title>
+			<link>/2</link>
+		</item>
+	</channel>
+</rss>`)
+
+	if expected := []string{"News", "First & foremost", "Second"}; !reflect.DeepEqual(titles, expected) {
+		t.Log("bad titles; actual", titles, "expected", expected)
+		t.Fail()
+	}
+	if expected := []string{"https://example.com/", "https://example.com/1", "/2"}; !reflect.DeepEqual(links, expected) {
+		t.Log("bad links; actual", links, "expected", expected)
+		t.Fail()
+	}
+}
+
+func TestAtomFilter(t *testing.T) {
+	titles, links := collectFilter(t, RSSFilter(), `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title>Example Feed</title>
+	<link href="http://example.org/"/>
+	<link rel="self" href="http://example.org/feed.atom"/>
+	<entry>
+		<title>Atom-Powered Robots Run Amok</title>
+		<link rel="alternate" href="http://example.org/2003/12/13/atom03"/>
+		<link rel="edit" href="http://example.org/2003/12/13/atom03/edit"/>
+	</entry>
+</feed>`)
+
+	if expected := []string{"Example Feed", "Atom-Powered Robots Run Amok"}; !reflect.DeepEqual(titles, expected) {
+		t.Log("bad titles; actual", titles, "expected", expected)
+		t.Fail()
+	}
+	if expected := []string{"http://example.org/", "http://example.org/2003/12/13/atom03"}; !reflect.DeepEqual(links, expected) {
+		t.Log("bad links; actual", links, "expected", expected)
+		t.Fail()
+	}
+}
+
+func TestRSSFilterLinkContext(t *testing.T) {
+	var links []Link
+	if err := RSSFilter()(context.Background(), strings.NewReader(`<feed xmlns="http://www.w3.org/2005/Atom">
+	<entry><link rel="alternate" href="https://example.com/a"/></entry>
+</feed>`), func(pos int, title string) error {
+		return nil
+	}, func(pos int, link Link) error {
+		links = append(links, link)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []Link{{Href: "https://example.com/a", Rel: "alternate", Tag: "link"}}; !reflect.DeepEqual(links, expected) {
+		t.Log("bad links; actual", links, "expected", expected)
+		t.Fail()
+	}
+}
@@ -0,0 +1,109 @@
+package crawler
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Scope limits links passed to YieldURLFunc. Host and domain rules are checked against the seed the link was discovered from.
+// Zero value allows everything.
+type Scope struct {
+	// Link must have the same host as the seed
+	SameHost bool
+
+	// Link must have the same registrable domain (eTLD+1) as the seed, e.g. blog.example.com for www.example.com
+	SameDomain bool
+
+	// Link path must start with one of prefixes, if any
+	PathPrefixes []string
+
+	// Link must match one of regexps, if any
+	Include []*regexp.Regexp
+
+	// Link must not match any of regexps
+	Exclude []*regexp.Regexp
+
+	// At most this many distinct links of every host are passed to YieldURLFunc; repeats of them pass too. Zero for no limit
+	MaxLinksPerHost int
+}
+
+type scopeCounter struct {
+	lock  sync.Mutex
+	hosts map[string]map[string]bool //> Links passed, without fragments
+}
+
+func (cr *crawl) inScope(seed, link *url.URL) bool {
+	s := &cr.ops.Scope
+
+	if s.SameHost || s.SameDomain {
+		rel := cr.relation(seed, link)
+
+		if s.SameHost && rel != SameHost {
+			return false
+		}
+		if s.SameDomain && rel == External {
+			return false
+		}
+	}
+
+	if len(s.PathPrefixes) > 0 {
+		ok := false
+		for _, prefix := range s.PathPrefixes {
+			if strings.HasPrefix(link.Path, prefix) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	if len(s.Include) > 0 || len(s.Exclude) > 0 {
+		str := link.String()
+
+		if len(s.Include) > 0 {
+			ok := false
+			for _, rx := range s.Include {
+				if rx.MatchString(str) {
+					ok = true
+					break
+				}
+			}
+			if !ok {
+				return false
+			}
+		}
+
+		for _, rx := range s.Exclude {
+			if rx.MatchString(str) {
+				return false
+			}
+		}
+	}
+
+	if s.MaxLinksPerHost > 0 {
+		cr.scopeCounter.lock.Lock()
+		defer cr.scopeCounter.lock.Unlock()
+
+		key := *link
+		key.Fragment = ""
+
+		links, ok := cr.scopeCounter.hosts[link.Host]
+		if !ok {
+			links = map[string]bool{}
+			cr.scopeCounter.hosts[link.Host] = links
+		}
+
+		if !links[key.String()] {
+			if len(links) >= s.MaxLinksPerHost {
+				return false
+			}
+			links[key.String()] = true
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,90 @@
+package crawler
+
+import (
+	"fmt"
+	"github.com/themakers/simple-crawler/psl"
+	"net/url"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestScope(t *testing.T) {
+	seed, _ := url.Parse("https://www.example.co.uk/docs/")
+
+	for _, c := range []struct {
+		scope    Scope
+		link     string
+		expected bool
+	}{
+		{Scope{}, "https://other.com/", true},
+		{Scope{SameHost: true}, "https://www.example.co.uk/about", true},
+		{Scope{SameHost: true}, "https://blog.example.co.uk/", false},
+		{Scope{SameDomain: true}, "https://blog.example.co.uk/", true},
+		{Scope{SameDomain: true}, "https://another.co.uk/", false},
+		{Scope{PathPrefixes: []string{"/docs/", "/api/"}}, "https://www.example.co.uk/api/v1", true},
+		{Scope{PathPrefixes: []string{"/docs/", "/api/"}}, "https://www.example.co.uk/blog", false},
+		{Scope{Include: []*regexp.Regexp{regexp.MustCompile(`\.html$`)}}, "https://www.example.co.uk/a.html", true},
+		{Scope{Include: []*regexp.Regexp{regexp.MustCompile(`\.html$`)}}, "https://www.example.co.uk/a.pdf", false},
+		{Scope{Exclude: []*regexp.Regexp{regexp.MustCompile(`[?&]page=`)}}, "https://www.example.co.uk/?page=2", false},
+	} {
+		cr := (&Crawler{ops: Options{Scope: c.scope, PublicSuffixList: psl.Default()}}).newCrawl()
+
+		link, _ := url.Parse(c.link)
+		if actual := cr.inScope(seed, link); actual != c.expected {
+			t.Log("bad scope check of", c.link, "; actual", actual, "expected", c.expected)
+			t.Fail()
+		}
+	}
+}
+
+func TestScopeMaxLinksPerHost(t *testing.T) {
+	seed, _ := url.Parse("https://example.com/")
+
+	cr := (&Crawler{ops: Options{Scope: Scope{MaxLinksPerHost: 2}}}).newCrawl()
+
+	var passed []bool
+	for _, link := range []string{"https://example.com/1", "https://other.com/1", "https://example.com/2", "https://example.com/3"} {
+		u, _ := url.Parse(link)
+		passed = append(passed, cr.inScope(seed, u))
+	}
+
+	if !(passed[0] && passed[1] && passed[2] && !passed[3]) {
+		t.Log("bad host limit; actual", passed)
+		t.Fail()
+	}
+}
+
+func TestScopeMaxLinksPerHostRepeated(t *testing.T) {
+	seed, _ := url.Parse("https://example.com/")
+
+	cr := (&Crawler{ops: Options{Scope: Scope{MaxLinksPerHost: 6}}}).newCrawl()
+
+	nav := []string{"https://example.com/", "https://example.com/about", "https://example.com/contact#form"}
+
+	var passed []string
+	for i := 0; i < 5; i++ {
+		//> Every page repeats the navbar before its own link
+		for _, link := range nav {
+			u, _ := url.Parse(link)
+			if !cr.inScope(seed, u) {
+				t.Log("repeated link is not passed", link)
+				t.Fail()
+			}
+		}
+
+		link := fmt.Sprintf("https://example.com/%d", i)
+		u, _ := url.Parse(link)
+		if cr.inScope(seed, u) {
+			passed = append(passed, link)
+		}
+	}
+
+	//> 3 nav links and 3 distinct ones
+	expected := []string{"https://example.com/0", "https://example.com/1", "https://example.com/2"}
+	if !reflect.DeepEqual(passed, expected) {
+		t.Log("bad links passed; actual", passed, "expected", expected)
+		t.Fail()
+	}
+
+}
@@ -0,0 +1,346 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//> Sitemaps protocol limits uncompressed sitemap size to 50MiB
+	maxSitemapSize = 50 * 1024 * 1024
+
+	//> Sitemap indexes are not supposed to be nested at all, but some sites do it anyway
+	maxSitemapNesting = 4
+)
+
+// SitemapURL is a single <url> entry of a sitemap, or a line of a text sitemap
+type SitemapURL struct {
+	Loc string
+
+	// Zero if not specified
+	LastMod time.Time
+
+	// Empty if not specified
+	ChangeFreq string
+
+	// 0.5 if not specified, as the protocol says
+	Priority float64
+}
+
+// Return false to skip the entry
+type YieldSitemapURLFunc func(sitemap *Page, entry SitemapURL) bool
+
+var sitemapTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+func parseSitemapTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range sitemapTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// parseSitemap reads XML sitemaps, XML sitemap indexes and text sitemaps, gzipped or not.
+// yieldSitemap is called for entries of a sitemap index, yieldURL for regular entries.
+func parseSitemap(r io.Reader, yieldSitemap func(loc string) error, yieldURL func(entry SitemapURL) error) error {
+	br := bufio.NewReader(r)
+
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+
+		br = bufio.NewReader(gz)
+	}
+
+	br = bufio.NewReader(io.LimitReader(br, maxSitemapSize))
+
+	if bom, err := br.Peek(3); err == nil && bytes.Equal(bom, []byte{0xef, 0xbb, 0xbf}) {
+		_, _ = br.Discard(3)
+	}
+
+	//> Text sitemaps contain nothing but URLs, so anything starting with '<' is XML
+	for {
+		b, err := br.Peek(1)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if b[0] == ' ' || b[0] == '\t' || b[0] == '\r' || b[0] == '\n' {
+			_, _ = br.ReadByte()
+			continue
+		}
+		if b[0] != '<' {
+			return parseTextSitemap(br, yieldURL)
+		}
+		break
+	}
+
+	dec := xml.NewDecoder(br)
+	dec.Strict = false
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "url":
+			var entry struct {
+				Loc        string `xml:"loc"`
+				LastMod    string `xml:"lastmod"`
+				ChangeFreq string `xml:"changefreq"`
+				Priority   string `xml:"priority"`
+			}
+			if err := dec.DecodeElement(&entry, &start); err != nil {
+				return err
+			}
+
+			loc := strings.TrimSpace(entry.Loc)
+			if loc == "" {
+				continue
+			}
+
+			priority := 0.5
+			if p, err := strconv.ParseFloat(strings.TrimSpace(entry.Priority), 64); err == nil {
+				priority = p
+			}
+
+			if err := yieldURL(SitemapURL{
+				Loc:        loc,
+				LastMod:    parseSitemapTime(entry.LastMod),
+				ChangeFreq: strings.ToLower(strings.TrimSpace(entry.ChangeFreq)),
+				Priority:   priority,
+			}); err != nil {
+				return err
+			}
+
+		case "sitemap":
+			var entry struct {
+				Loc string `xml:"loc"`
+			}
+			if err := dec.DecodeElement(&entry, &start); err != nil {
+				return err
+			}
+
+			if loc := strings.TrimSpace(entry.Loc); loc != "" {
+				if err := yieldSitemap(loc); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// parseTextSitemap yields lines being absolute http(s) URLs, as the protocol requires;
+// anything else is skipped, so an error page served instead of the sitemap yields nothing
+func parseTextSitemap(r io.Reader, yieldURL func(entry SitemapURL) error) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if u, err := url.Parse(line); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			continue
+		}
+		if err := yieldURL(SitemapURL{Loc: line, Priority: 0.5}); err != nil {
+			return err
+		}
+	}
+	return s.Err()
+}
+
+// parseRobotsSitemaps extracts 'Sitemap:' lines from robots.txt
+func parseRobotsSitemaps(r io.Reader) ([]string, error) {
+	var sitemaps []string
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+
+		if strings.EqualFold(strings.TrimSpace(line[:i]), "sitemap") {
+			if loc := strings.TrimSpace(line[i+1:]); loc != "" {
+				sitemaps = append(sitemaps, loc)
+			}
+		}
+	}
+
+	return sitemaps, s.Err()
+}
+
+var errNotFound = errors.New("not found")
+
+func (cr *Crawler) fetch(ctx context.Context, link, referer string, fn func(r io.Reader) error) error {
+	req, err := cr.newRequest(withRequestInfo(ctx, &requestInfo{priority: sitemapPriority}), link, referer)
+	if err != nil {
+		return err
+	}
+
+	resp, err := cr.request(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return errNotFound
+	} else if resp.StatusCode != http.StatusOK {
+		return &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	return fn(resp.Body)
+}
+
+// handleSitemaps discovers sitemaps of the seed's host and crawls their entries as if they were seeds
+func (cr *crawl) handleSitemaps(ctx context.Context, seed *url.URL, initialDepth, depth int, meta Meta) {
+	root := &url.URL{Scheme: seed.Scheme, Host: seed.Host, Path: "/"}
+
+	robots := root.ResolveReference(&url.URL{Path: "/robots.txt"}).String()
+	sitemaps := []string{root.ResolveReference(&url.URL{Path: "/sitemap.xml"}).String()}
+
+	if err := cr.fetch(ctx, robots, "", func(r io.Reader) error {
+		locs, err := parseRobotsSitemaps(io.LimitReader(r, maxSitemapSize))
+		sitemaps = append(locs, sitemaps...)
+		return err
+	}); err != nil && err != errNotFound {
+		cr.fail(seed.Host, false, &Page{URL: robots, Depth: depth, Meta: meta}, "", -1, err)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		lock sync.Mutex
+		seen = map[string]bool{}
+	)
+	defer wg.Wait()
+
+	var walk func(sitemap string, nesting int, implicit bool)
+	walk = func(sitemap string, nesting int, implicit bool) {
+		lock.Lock()
+		if seen[sitemap] || nesting > maxSitemapNesting {
+			lock.Unlock()
+			return
+		}
+		seen[sitemap] = true
+		lock.Unlock()
+
+		page := &Page{URL: sitemap, Depth: depth, Meta: meta}
+
+		sitemapURL, err := url.Parse(sitemap)
+		if err != nil {
+			cr.fail(seed.Host, false, page, "", -1, err)
+			return
+		}
+
+		var parseErr error
+		err = cr.fetch(ctx, sitemap, "", func(r io.Reader) error {
+			parseErr = parseSitemap(r, func(loc string) error {
+				locURL, err := url.Parse(loc)
+				if err != nil {
+					cr.fail(seed.Host, false, page, loc, -1, err)
+					return nil
+				}
+
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					walk(sitemapURL.ResolveReference(locURL).String(), nesting+1, false)
+				}()
+
+				return nil
+			}, func(entry SitemapURL) error {
+				if cr.budget.stopped() {
+					return nil
+				}
+
+				if cr.ops.YieldSitemapURL != nil && !cr.ops.YieldSitemapURL(page, entry) {
+					return nil
+				}
+
+				locURL, err := url.Parse(entry.Loc)
+				if err != nil {
+					cr.fail(seed.Host, false, page, entry.Loc, -1, err)
+					return nil
+				}
+
+				absURL := cr.ops.URLNormalizer.Normalize(sitemapURL.ResolveReference(locURL))
+
+				if !cr.inScope(seed, absURL) {
+					return nil
+				}
+
+				if !cr.yieldURL(page, -1, Link{Href: entry.Loc}, &(*absURL), cr.relation(seed, absURL)) {
+					return nil
+				}
+
+				absURL.Fragment = ""
+
+				if absURL.Scheme == "http" || absURL.Scheme == "https" {
+					if cr.ops.PrefetchDNS {
+						cr.resolver.prefetch(absURL.Hostname())
+					}
+
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						cr.handle(ctx, seed, initialDepth, &Page{
+							URL:    absURL.String(),
+							Depth:  depth,
+							Meta:   meta,
+							Path:   []string{sitemap},
+							Anchor: Link{Href: entry.Loc},
+						})
+					}()
+				}
+
+				return nil
+			})
+			return parseErr
+		})
+
+		//> Missing /sitemap.xml is often served as a regular HTML page, which is not an XML
+		if err != nil && !(implicit && (err == errNotFound || err == parseErr)) {
+			cr.fail(seed.Host, false, page, "", -1, err)
+		}
+	}
+
+	for i, sitemap := range sitemaps {
+		//> The last one is /sitemap.xml, which is fine to be missing
+		walk(sitemap, 0, i == len(sitemaps)-1)
+	}
+}
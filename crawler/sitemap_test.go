@@ -0,0 +1,163 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func collectSitemap(t *testing.T, data []byte) (sitemaps []string, entries []SitemapURL) {
+	if err := parseSitemap(bytes.NewReader(data), func(loc string) error {
+		sitemaps = append(sitemaps, loc)
+		return nil
+	}, func(entry SitemapURL) error {
+		entries = append(entries, entry)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestParseSitemap(t *testing.T) {
+	const urlset = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<url>
+		<loc>https://example.com/</loc>
+		<lastmod>2020-03-01</lastmod>
+		<changefreq>Daily</changefreq>
+		<priority>0.8</priority>
+	</url>
+	<url>
+		<loc> https://example.com/about </loc>
+	</url>
+</urlset>`
+
+	expected := []SitemapURL{
+		{Loc: "https://example.com/", LastMod: time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC), ChangeFreq: "daily", Priority: 0.8},
+		{Loc: "https://example.com/about", Priority: 0.5},
+	}
+
+	_, entries := collectSitemap(t, []byte(urlset))
+	if !reflect.DeepEqual(entries, expected) {
+		t.Log("bad entries; actual", entries, "expected", expected)
+		t.Fail()
+	}
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, _ = w.Write([]byte(urlset))
+	_ = w.Close()
+
+	_, entries = collectSitemap(t, gz.Bytes())
+	if !reflect.DeepEqual(entries, expected) {
+		t.Log("bad gzipped entries; actual", entries, "expected", expected)
+		t.Fail()
+	}
+}
+
+func TestParseSitemapIndex(t *testing.T) {
+	sitemaps, entries := collectSitemap(t, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<sitemap><loc>https://example.com/sitemap1.xml.gz</loc><lastmod>2004-10-01T18:23:17+00:00</lastmod></sitemap>
+	<sitemap><loc>https://example.com/sitemap2.xml</loc></sitemap>
+</sitemapindex>`))
+
+	expected := []string{"https://example.com/sitemap1.xml.gz", "https://example.com/sitemap2.xml"}
+	if !reflect.DeepEqual(sitemaps, expected) || len(entries) != 0 {
+		t.Log("bad sitemaps; actual", sitemaps, entries, "expected", expected)
+		t.Fail()
+	}
+}
+
+func TestParseTextSitemap(t *testing.T) {
+	_, entries := collectSitemap(t, []byte("\nhttps://example.com/a\n\nhttps://example.com/b\r\n/relative\nftp://example.com/c\nhttps:///nohost\n"))
+
+	expected := []SitemapURL{
+		{Loc: "https://example.com/a", Priority: 0.5},
+		{Loc: "https://example.com/b", Priority: 0.5},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Log("bad entries; actual", entries, "expected", expected)
+		t.Fail()
+	}
+}
+
+func TestParseTextSitemapSoft404(t *testing.T) {
+	_, entries := collectSitemap(t, []byte("Page not found\nSorry!\n"))
+
+	if len(entries) != 0 {
+		t.Log("bad entries of a not found page", entries)
+		t.Fail()
+	}
+}
+
+func TestParseRobotsSitemaps(t *testing.T) {
+	sitemaps, err := parseRobotsSitemaps(strings.NewReader(`User-agent: *
+Disallow: /private # comment
+sitemap: https://example.com/sitemap_index.xml
+Sitemap:https://example.com/news.xml
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"https://example.com/sitemap_index.xml", "https://example.com/news.xml"}
+	if !reflect.DeepEqual(sitemaps, expected) {
+		t.Log("bad sitemaps; actual", sitemaps, "expected", expected)
+		t.Fail()
+	}
+}
+
+func TestHandleSitemaps(t *testing.T) {
+	var (
+		lock   sync.Mutex
+		served = map[string]int{}
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, q *http.Request) {
+		lock.Lock()
+		served[q.URL.Path]++
+		lock.Unlock()
+
+		base := "http://" + q.Host
+
+		switch q.URL.Path {
+		case "/robots.txt":
+			_, _ = fmt.Fprintf(w, "User-agent: *\nSitemap: %s/sitemap_index.xml\n", base)
+		case "/sitemap_index.xml":
+			_, _ = fmt.Fprintf(w, `<sitemapindex><sitemap><loc>%s/sitemap1.xml</loc></sitemap></sitemapindex>`, base)
+		case "/sitemap1.xml":
+			_, _ = fmt.Fprintf(w, `<urlset><url><loc>%s/a</loc></url><url><loc>%s/b</loc></url></urlset>`, base, base)
+		default:
+			//> Including the soft 404 of /sitemap.xml
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = fmt.Fprint(w, `<!DOCTYPE html><html><head><title>Not found</title><script>if (a < b) {}</script></head><body>Sorry!</body></html>`)
+		}
+	}))
+	defer srv.Close()
+
+	cr := newTestCrawler(t, Options{
+		Sitemaps: true,
+		Dedup:    true,
+	})
+
+	if _, err := cr.Feed(context.Background(), 1, srv.URL+"/"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/", "/robots.txt", "/sitemap_index.xml", "/sitemap1.xml", "/sitemap.xml", "/a", "/b"} {
+		if served[path] != 1 {
+			t.Log("bad number of requests of", path, "; actual", served[path], "expected", 1)
+			t.Fail()
+		}
+	}
+}
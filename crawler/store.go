@@ -0,0 +1,199 @@
+package crawler
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Bytes read past the end the content handler stopped at to hash the body; state of larger bodies is not remembered
+const maxStateTail = 1 << 20
+
+// PageState is what crawler remembers about a fetched page to make conditional requests on the next visit
+type PageState struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+
+	// Hex-encoded SHA-256 of the body
+	Hash string `json:"hash,omitempty"`
+
+	// First time the page was fetched
+	First time.Time `json:"first"`
+
+	// Last time the page was fetched or confirmed to be unchanged
+	Checked time.Time `json:"checked"`
+
+	// Last time the page content was seen changed
+	Changed time.Time `json:"changed"`
+
+	Checks  int `json:"checks"`
+	Changes int `json:"changes"`
+}
+
+type StateStore interface {
+	Load(link string) (state PageState, ok bool)
+	Store(link string, state PageState)
+
+	// Stops when fn returns false
+	Range(fn func(link string, state PageState) bool)
+}
+
+// MemoryStore keeps page states in memory; useful for recrawling within a single process
+type MemoryStore struct {
+	lock  sync.RWMutex
+	pages map[string]PageState
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{pages: map[string]PageState{}}
+}
+
+func (s *MemoryStore) Load(link string) (PageState, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	state, ok := s.pages[link]
+	return state, ok
+}
+
+func (s *MemoryStore) Store(link string, state PageState) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.pages[link] = state
+}
+
+func (s *MemoryStore) Range(fn func(link string, state PageState) bool) {
+	s.lock.RLock()
+	pages := make(map[string]PageState, len(s.pages))
+	for link, state := range s.pages {
+		pages[link] = state
+	}
+	s.lock.RUnlock()
+
+	for link, state := range pages {
+		if !fn(link, state) {
+			return
+		}
+	}
+}
+
+// FileStore is a MemoryStore persisted to a JSON file between runs
+type FileStore struct {
+	MemoryStore
+	path string
+}
+
+// OpenFileStore loads page states from the file, if it exists
+func OpenFileStore(path string) (*FileStore, error) {
+	s := &FileStore{
+		MemoryStore: MemoryStore{pages: map[string]PageState{}},
+		path:        path,
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &s.pages); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Save atomically writes page states to the file
+func (s *FileStore) Save() error {
+	s.lock.RLock()
+	data, err := json.Marshal(s.pages)
+	s.lock.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func (cr *Crawler) setValidators(req *http.Request, link string) {
+	if cr.ops.State == nil {
+		return
+	}
+
+	if state, ok := cr.ops.State.Load(link); ok {
+		if state.ETag != "" {
+			req.Header.Set("If-None-Match", state.ETag)
+		}
+		if state.LastModified != "" {
+			req.Header.Set("If-Modified-Since", state.LastModified)
+		}
+	}
+}
+
+// pageNotModified handles 304 responses
+func (cr *Crawler) pageNotModified(page *Page, header http.Header) {
+	link := page.URL
+
+	state, _ := cr.ops.State.Load(link)
+
+	state.Checked = time.Now()
+	state.Checks++
+	if etag := header.Get("ETag"); etag != "" {
+		state.ETag = etag
+	}
+
+	cr.ops.State.Store(link, state)
+
+	if cr.ops.YieldUnchanged != nil {
+		cr.ops.YieldUnchanged(page, state)
+	}
+}
+
+// pageFetched records the state of a successfully fetched page
+func (cr *Crawler) pageFetched(page *Page, header http.Header, hash string) {
+	link := page.URL
+	now := time.Now()
+
+	state, ok := cr.ops.State.Load(link)
+	if !ok {
+		state.First = now
+	}
+
+	unchanged := ok && state.Hash == hash
+
+	state.ETag = header.Get("ETag")
+	state.LastModified = header.Get("Last-Modified")
+	state.Hash = hash
+	state.Checked = now
+	state.Checks++
+	if !unchanged {
+		state.Changed = now
+		state.Changes++
+	}
+
+	cr.ops.State.Store(link, state)
+
+	if unchanged && cr.ops.YieldUnchanged != nil {
+		cr.ops.YieldUnchanged(page, state)
+	}
+}
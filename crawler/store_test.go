@@ -0,0 +1,213 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, q *http.Request) {
+		if q.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	store, err := OpenFileStore(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var unchanged []string
+
+	cr := newTestCrawler(t, Options{
+		State: store,
+		YieldUnchanged: func(page *Page, state PageState) {
+			unchanged = append(unchanged, page.URL)
+		},
+	})
+
+	if _, err := cr.Feed(context.Background(), 1, srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = OpenFileStore(store.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr.ops.State = store
+
+	if _, err := cr.Feed(context.Background(), 1, srv.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	//> Seeds are normalized
+	link := srv.URL + "/"
+
+	state, ok := store.Load(link)
+	if !ok || state.ETag != `"v1"` || state.Checks != 2 || state.Changes != 1 || state.Hash == "" {
+		t.Log("bad state", state)
+		t.Fail()
+	}
+
+	if len(unchanged) != 1 || unchanged[0] != link {
+		t.Log("bad unchanged pages; actual", unchanged)
+		t.Fail()
+	}
+}
+
+func TestNextVisit(t *testing.T) {
+	t0 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, c := range []struct {
+		state    PageState
+		expected time.Time
+	}{
+		//> Just fetched
+		{PageState{First: t0, Checked: t0, Changes: 1}, t0.Add(time.Hour)},
+		//> Never changed during a day
+		{PageState{First: t0, Checked: t0.Add(24 * time.Hour), Changes: 1}, t0.Add(48 * time.Hour)},
+		//> Changed 4 times during 4 days
+		{PageState{First: t0, Checked: t0.Add(96 * time.Hour), Changes: 5}, t0.Add(120 * time.Hour)},
+		//> Never changed for a month
+		{PageState{First: t0, Checked: t0.Add(30 * 24 * time.Hour), Changes: 1}, t0.Add(37 * 24 * time.Hour)},
+	} {
+		if actual := NextVisit(c.state, time.Hour, 7*24*time.Hour); !actual.Equal(c.expected) {
+			t.Log("bad next visit; actual", actual, "expected", c.expected)
+			t.Fail()
+		}
+	}
+}
+
+func TestRecrawlerYieldRound(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, q *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(q.URL.Path))
+	}))
+	defer srv.Close()
+
+	store := NewMemoryStore()
+	for _, path := range []string{"/a", "/b"} {
+		store.Store(srv.URL+path, PageState{Changes: 1})
+	}
+
+	cr := newTestCrawlerFunc(func(page *Page, link string, pos int, err error) {
+	}, Options{
+		State:    store,
+		MaxPages: 1,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		results []*CrawlResult
+		errs    []error
+	)
+
+	rc := &Recrawler{
+		Crawler:     cr,
+		Store:       store,
+		MinInterval: time.Hour,
+		YieldRound: func(result *CrawlResult, err error) {
+			results = append(results, result)
+			errs = append(errs, err)
+			cancel()
+		},
+	}
+
+	if err := rc.Run(ctx, time.Hour); err != context.Canceled {
+		t.Log("bad run error", err)
+		t.Fail()
+	}
+
+	var budgetErr *BudgetExceededError
+	if len(results) != 1 || results[0].Pages != 1 || !errors.As(errs[0], &budgetErr) {
+		t.Log("bad rounds; actual", results, errs)
+		t.Fail()
+	}
+}
+
+func TestStateSkipsUnhandledContent(t *testing.T) {
+	image := make([]byte, 1<<20)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, q *http.Request) {
+		if q.URL.Path == "/image.png" {
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(image)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("http://" + q.Host + "/image.png"))
+	}))
+	defer srv.Close()
+
+	store := NewMemoryStore()
+
+	cr := newTestCrawler(t, Options{
+		State: store,
+	})
+
+	result, err := cr.Feed(context.Background(), 2, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Bytes >= int64(len(image)) {
+		t.Log("unhandled content is downloaded; bytes", result.Bytes)
+		t.Fail()
+	}
+
+	if _, ok := store.Load(srv.URL + "/image.png"); ok {
+		t.Log("state of unhandled content is stored")
+		t.Fail()
+	}
+	if _, ok := store.Load(srv.URL + "/"); !ok {
+		t.Log("state of the page is not stored")
+		t.Fail()
+	}
+}
+
+func TestStateTailCap(t *testing.T) {
+	pdf := make([]byte, 8<<20)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, q *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		_, _ = w.Write(pdf)
+	}))
+	defer srv.Close()
+
+	store := NewMemoryStore()
+
+	cr := newTestCrawler(t, Options{
+		State: store,
+	})
+	cr.Handle("application/pdf", PDFFilter(1024))
+
+	result, err := cr.Feed(context.Background(), 1, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Bytes > 2<<20 {
+		t.Log("size cap of the handler is ignored; bytes", result.Bytes)
+		t.Fail()
+	}
+
+	if _, ok := store.Load(srv.URL + "/"); ok {
+		t.Log("state of the partly read page is stored")
+		t.Fail()
+	}
+}
@@ -0,0 +1,47 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var textLinkRx = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"'` + "`" + `]+`)
+
+// TextFilter extracts absolute http(s) URLs from plain text
+func TextFilter() FilterFunc {
+	return func(ctx context.Context, r io.Reader, yieldTitle func(pos int, title string) error, yieldLink func(pos int, link Link) error) error {
+		s := bufio.NewScanner(r)
+		s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+		offset := 0
+
+		for s.Scan() {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+
+			line := s.Text()
+
+			for _, match := range textLinkRx.FindAllStringIndex(line, -1) {
+				//> Trailing punctuation most likely belongs to the sentence, not to the URL
+				link := strings.TrimRight(line[match[0]:match[1]], ".,;:!?")
+				if strings.HasSuffix(link, ")") && !strings.Contains(link, "(") {
+					link = strings.TrimRight(link, ")")
+				}
+
+				if err := yieldLink(offset+match[0], Link{Href: link}); err != nil {
+					return err
+				}
+			}
+
+			offset += len(line) + 1
+		}
+
+		return s.Err()
+	}
+}
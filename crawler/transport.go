@@ -0,0 +1,158 @@
+package crawler
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Timeouts of the default Client; every one applies to a single step of a request, so a slow
+// but steadily responding server isn't cut off in the middle of a large page
+type Timeouts struct {
+	// Zero for 10 seconds
+	Dial time.Duration
+
+	// Zero for 10 seconds
+	TLSHandshake time.Duration
+
+	// Time from the request written to the response headers read; zero for 15 seconds
+	ResponseHeader time.Duration
+
+	// Time the response body may stall for, i.e. the longest pause between reads of data; zero for 30 seconds
+	Body time.Duration
+}
+
+// Idle connections kept per host when ParallelRequestsPerHost is unlimited
+const defaultIdleConnsPerHost = 16
+
+// newTransport is the transport of the default Client
+func (cr *Crawler) newTransport() http.RoundTripper {
+	timeouts := cr.ops.Timeouts
+	if timeouts.Dial == 0 {
+		timeouts.Dial = 10 * time.Second
+	}
+	if timeouts.TLSHandshake == 0 {
+		timeouts.TLSHandshake = 10 * time.Second
+	}
+	if timeouts.ResponseHeader == 0 {
+		timeouts.ResponseHeader = 15 * time.Second
+	}
+	if timeouts.Body == 0 {
+		timeouts.Body = 30 * time.Second
+	}
+
+	//> Connections are reused by requests in flight to the host, so keep as many of them
+	idleConnsPerHost := cr.ops.MaxIdleConnsPerHost
+	if idleConnsPerHost == 0 {
+		idleConnsPerHost = cr.ops.ParallelRequestsPerHost
+	}
+	if idleConnsPerHost <= 0 {
+		idleConnsPerHost = defaultIdleConnsPerHost
+	}
+
+	idleConnTimeout := cr.ops.IdleConnTimeout
+	if idleConnTimeout == 0 {
+		idleConnTimeout = 30 * time.Second
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: cr.resolver.dial(&net.Dialer{
+			Timeout:   timeouts.Dial,
+			KeepAlive: 30 * time.Second,
+		}),
+		TLSHandshakeTimeout:   timeouts.TLSHandshake,
+		ResponseHeaderTimeout: timeouts.ResponseHeader,
+		ExpectContinueTimeout: 1 * time.Second,
+
+		//> Zero for no limit; crawls of many hosts are bounded by IdleConnTimeout instead
+		MaxIdleConns:        cr.ops.MaxParallelRequests * 2,
+		MaxIdleConnsPerHost: idleConnsPerHost,
+		IdleConnTimeout:     idleConnTimeout,
+
+		ForceAttemptHTTP2: !cr.ops.DisableHTTP2,
+	}
+
+	if cr.ops.DisableHTTP2 {
+		//> Non-nil empty map turns HTTP/2 off, see net/http docs
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	return &bodyTimeoutTransport{transport: transport, timeout: timeouts.Body}
+}
+
+// bodyTimeoutTransport closes response bodies stalled for longer than the timeout
+type bodyTimeoutTransport struct {
+	transport http.RoundTripper
+	timeout   time.Duration
+}
+
+func (t *bodyTimeoutTransport) RoundTrip(q *http.Request) (*http.Response, error) {
+	resp, err := t.transport.RoundTrip(q)
+	if err != nil {
+		return nil, err
+	}
+
+	body := &timeoutBody{body: resp.Body, timeout: t.timeout}
+	body.timer = time.AfterFunc(t.timeout, body.expire)
+	resp.Body = body
+
+	return resp, nil
+}
+
+func (t *bodyTimeoutTransport) CloseIdleConnections() {
+	if transport, ok := t.transport.(interface{ CloseIdleConnections() }); ok {
+		transport.CloseIdleConnections()
+	}
+}
+
+type timeoutBody struct {
+	body    io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+
+	lock    sync.Mutex
+	expired bool
+}
+
+func (b *timeoutBody) expire() {
+	b.lock.Lock()
+	b.expired = true
+	b.lock.Unlock()
+
+	_ = b.body.Close() //> Unblocks a pending Read
+}
+
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if n > 0 {
+		b.timer.Reset(b.timeout)
+	}
+	if err != nil && err != io.EOF {
+		b.lock.Lock()
+		expired := b.expired
+		b.lock.Unlock()
+
+		if expired {
+			return n, errBodyTimeout
+		}
+	}
+	return n, err
+}
+
+func (b *timeoutBody) Close() error {
+	b.timer.Stop()
+	return b.body.Close()
+}
+
+// errBodyTimeout is classified as ErrorTimeout
+var errBodyTimeout net.Error = bodyTimeoutError{}
+
+type bodyTimeoutError struct{}
+
+func (bodyTimeoutError) Error() string   { return "crawler: timeout reading response body" }
+func (bodyTimeoutError) Timeout() bool   { return true }
+func (bodyTimeoutError) Temporary() bool { return true }
@@ -0,0 +1,96 @@
+package crawler
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTransportIdleConns(t *testing.T) {
+	for _, c := range []struct {
+		ops  Options
+		idle int
+	}{
+		{Options{}, defaultIdleConnsPerHost},
+		{Options{ParallelRequestsPerHost: 10}, 10},
+		{Options{ParallelRequestsPerHost: 10, MaxIdleConnsPerHost: 4}, 4},
+	} {
+		cr := New(nil, nil, nil, nil, c.ops)
+		transport := cr.ops.Client.Transport.(*bodyTimeoutTransport).transport.(*http.Transport)
+
+		if transport.MaxIdleConnsPerHost != c.idle {
+			t.Log("idle conns per host", c.ops, transport.MaxIdleConnsPerHost, c.idle)
+			t.Fail()
+		}
+		if transport.TLSNextProto != nil || !transport.ForceAttemptHTTP2 {
+			t.Log("HTTP/2 is not enabled by default")
+			t.Fail()
+		}
+	}
+
+	cr := New(nil, nil, nil, nil, Options{DisableHTTP2: true})
+	transport := cr.ops.Client.Transport.(*bodyTimeoutTransport).transport.(*http.Transport)
+	if transport.TLSNextProto == nil || transport.ForceAttemptHTTP2 {
+		t.Log("HTTP/2 is not disabled")
+		t.Fail()
+	}
+}
+
+func TestTransportBodyTimeout(t *testing.T) {
+	done := make(chan struct{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, q *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		w.Write([]byte("<html>"))
+		w.(http.Flusher).Flush()
+		<-done
+	}))
+	defer srv.Close()
+	defer close(done) //> Before the server is closed, which waits for the handler
+
+	cr := New(nil, nil, nil, nil, Options{Timeouts: Timeouts{Body: 50 * time.Millisecond}})
+
+	t0 := time.Now()
+	resp, err := cr.ops.Client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	_, err = ioutil.ReadAll(resp.Body)
+	if ClassifyError(err) != ErrorTimeout {
+		t.Log("body read error", err)
+		t.Fail()
+	}
+	if time.Since(t0) > time.Second {
+		t.Log("body read took", time.Since(t0))
+		t.Fail()
+	}
+}
+
+func TestTransportBodyTimeoutSlowServer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, q *http.Request) {
+		for i := 0; i < 10; i++ {
+			w.Write([]byte("<p>"))
+			w.(http.Flusher).Flush()
+			time.Sleep(20 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	cr := New(nil, nil, nil, nil, Options{Timeouts: Timeouts{Body: 100 * time.Millisecond}})
+
+	resp, err := cr.ops.Client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	//> Takes longer than the timeout in total, but never stalls for that long
+	if data, err := ioutil.ReadAll(resp.Body); err != nil || len(data) != 30 {
+		t.Log("slow but steady body is cut off", len(data), err)
+		t.Fail()
+	}
+}
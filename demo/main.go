@@ -5,13 +5,13 @@ import (
 	"encoding/json"
 	"github.com/themakers/simple-crawler/crawler"
 	"github.com/themakers/simple-crawler/filters"
+	"github.com/themakers/simple-crawler/urlnorm"
 	"log"
 	"net/url"
 	"os"
 	"os/signal"
 	"strings"
 	"sync"
-	"sync/atomic"
 	"syscall"
 	"time"
 )
@@ -25,11 +25,10 @@ func main() {
 	go signalHandler(ctx, cancel)
 
 	var (
-		wg           sync.WaitGroup
-		crawled      = map[string]bool{}
-		crawledHosts = map[string]bool{}
-		crawledLock  sync.Mutex
-		tt           int64
+		wg          sync.WaitGroup
+		crawled     = map[string]bool{}
+		crawledLock sync.Mutex
+		result      *crawler.CrawlResult
 	)
 
 	wg.Add(1)
@@ -37,50 +36,49 @@ func main() {
 		defer cancel()
 		defer wg.Done()
 
-		t0 := time.Now()
-
 		cr := crawler.New(
-			filters.StreamingFSMLinksFilter(1024),
-			func(depth, pos int, origin string, title string) {
-				log.Printf("title found. origin = %s, pos = %d, title = %s;", origin, pos, title)
+			filters.StreamingGoHTMLLinksFilter(),
+			func(page *crawler.Page, pos int, title string) {
+				log.Printf("title found. origin = %s, pos = %d, title = %s;", page.URL, pos, title)
 			},
-			func(depth, pos int, origin string, originalLink string, link *url.URL, external bool) bool {
+			func(page *crawler.Page, pos int, found crawler.Link, link *url.URL, rel crawler.Relation) bool {
 
-				linkText := link.String()
-				link.Fragment = ""
 				linkKey := link.String()
 
 				crawledLock.Lock()
 				defer crawledLock.Unlock()
 
-				crawledHosts[link.Host] = true
-
 				if link.Scheme != "data" {
 					if crawled[linkKey] {
 						return false
 					} else {
 						crawled[linkKey] = true
-						log.Printf("url found. external = %t; originalLink = %s; origin = %s, pos = %d, link = %s;", external, originalLink, origin, pos, linkText)
-
-						atomic.StoreInt64(&tt, int64(time.Now().Sub(t0)))
+						log.Printf("url found. relation = %s; originalLink = %s; text = %q; origin = %s, pos = %d, link = %s;", rel, found.Href, found.Text, page.URL, pos, linkKey)
 						return true
 					}
 				} else {
-					log.Printf("data url found. origin = %s, pos = %d;", origin, pos)
+					log.Printf("data url found. origin = %s, pos = %d;", page.URL, pos)
 					return false
 				}
-			}, func(origin, link string, pos int, err error) {
+			}, func(page *crawler.Page, link string, pos int, err error) {
 				if !strings.Contains(err.Error(), context.Canceled.Error()) {
-					log.Printf("error. origin = %s, link = %s, pos = %d, error = %v;", origin, link, pos, err)
+					log.Printf("error. origin = %s, link = %s, pos = %d, error = %v;", page.URL, link, pos, err)
 				}
 			},
 			crawler.Options{
 				Depth:                   0,
 				ParallelRequestsPerHost: 10,
+				URLNormalizer:           &urlnorm.Normalizer{Flags: urlnorm.UsuallySafe},
+				HostLimitsOverrides: []crawler.HostLimitsRule{
+					{Pattern: "*.wikipedia.org", Limits: crawler.HostLimits{MaxPages: 1000}},
+				},
 			},
 		)
 
-		cr.Feed(ctx, 2,
+		cr.Handle("application/pdf", crawler.PDFFilter(10*1024*1024))
+
+		var err error
+		result, err = cr.Feed(ctx, 2,
 			"https://themake.rs",
 			"https://microsoft.com",
 			"https://xkcd.com",
@@ -92,6 +90,9 @@ func main() {
 			"https://reddit.com",
 			"https://en.wikipedia.org",
 		)
+		if err != nil {
+			log.Println("crawl stopped:", err)
+		}
 
 	}()
 
@@ -100,14 +101,14 @@ func main() {
 
 	wg.Wait()
 
-	if data, err := json.MarshalIndent(crawledHosts, "", "  "); err != nil {
+	if data, err := json.MarshalIndent(result, "", "  "); err != nil {
 		panic(err)
 	} else {
 		log.Println(string(data))
-		log.Println("Hosts total", len(crawledHosts))
+		log.Println("Hosts total", len(result.Hosts))
 		log.Println("Links total", len(crawled))
-		log.Println("Time spent", time.Duration(tt))
-		log.Println("Current speed", len(crawled)*int(time.Second)/int(tt), "links/sec")
+		log.Println("Time spent", result.Duration)
+		log.Println("Current speed", float64(result.Pages)/result.Duration.Seconds(), "pages/sec")
 	}
 }
 
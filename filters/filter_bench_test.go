@@ -3,6 +3,7 @@ package filters
 import (
 	"bytes"
 	"context"
+	"github.com/themakers/simple-crawler/crawler"
 	"io"
 	"io/ioutil"
 	"log"
@@ -45,7 +46,7 @@ func BenchmarkStreamingRegexpLinksFilter(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		if err := RegexpLinksFilter()(context.Background(), data(), func(pos int, title string) error {
 			return nil
-		}, func(pos int, link string) error {
+		}, func(pos int, link crawler.Link) error {
 			return nil
 		}); err != nil {
 			panic(err)
@@ -61,7 +62,7 @@ func BenchmarkStreamingLinksFilter1024(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		if err := filter(context.Background(), data(), func(pos int, title string) error {
 			return nil
-		}, func(pos int, link string) error {
+		}, func(pos int, link crawler.Link) error {
 			return nil
 		}); err != nil {
 			panic(err)
@@ -77,7 +78,7 @@ func BenchmarkStreamingScannerLinksFilter(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		if err := filter(context.Background(), data(), func(pos int, title string) error {
 			return nil
-		}, func(pos int, link string) error {
+		}, func(pos int, link crawler.Link) error {
 			return nil
 		}); err != nil {
 			panic(err)
@@ -91,7 +92,7 @@ func BenchmarkStreamingGoHTMLLinksFilter(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		if err := StreamingGoHTMLLinksFilter()(context.Background(), data(), func(pos int, title string) error {
 			return nil
-		}, func(pos int, link string) error {
+		}, func(pos int, link crawler.Link) error {
 			return nil
 		}); err != nil {
 			panic(err)
@@ -105,7 +106,7 @@ func BenchmarkGoQueryLinksFilter(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		if err := GoQueryLinksFilter()(context.Background(), data(), func(pos int, title string) error {
 			return nil
-		}, func(pos int, link string) error {
+		}, func(pos int, link crawler.Link) error {
 			return nil
 		}); err != nil {
 			panic(err)
@@ -118,9 +119,9 @@ func TestStreamingRegexpLinksFilter(t *testing.T) {
 	nlinks := 0
 	if err := RegexpLinksFilter()(context.Background(), data(), func(pos int, title string) error {
 		return nil
-	}, func(pos int, link string) error {
+	}, func(pos int, link crawler.Link) error {
 		nlinks += 1
-		t.Log("RegexpLinksFilter => ", link)
+		t.Log("RegexpLinksFilter => ", link.Href, link.Text)
 		return nil
 	}); err != nil {
 		panic(err)
@@ -132,9 +133,9 @@ func TestStreamingLinksFilter(t *testing.T) {
 	nlinks := 0
 	if err := StreamingFSMLinksFilter(1024)(context.Background(), data(), func(pos int, title string) error {
 		return nil
-	}, func(pos int, link string) error {
+	}, func(pos int, link crawler.Link) error {
 		nlinks += 1
-		t.Log("StreamingFSMLinksFilter => ", link)
+		t.Log("StreamingFSMLinksFilter => ", link.Href, link.Text)
 		return nil
 	}); err != nil {
 		panic(err)
@@ -146,9 +147,9 @@ func TestStreamingGoHTMLLinksFilter(t *testing.T) {
 	nlinks := 0
 	if err := StreamingGoHTMLLinksFilter()(context.Background(), data(), func(pos int, title string) error {
 		return nil
-	}, func(pos int, link string) error {
+	}, func(pos int, link crawler.Link) error {
 		nlinks += 1
-		t.Log("StreamingGoHTMLLinksFilter => ", link)
+		t.Log("StreamingGoHTMLLinksFilter => ", link.Href, link.Text)
 		return nil
 	}); err != nil {
 		panic(err)
@@ -160,9 +161,9 @@ func TestGoQueryLinksFilter(t *testing.T) {
 	nlinks := 0
 	if err := GoQueryLinksFilter()(context.Background(), data(), func(pos int, title string) error {
 		return nil
-	}, func(pos int, link string) error {
+	}, func(pos int, link crawler.Link) error {
 		nlinks += 1
-		t.Log("GoQueryLinksFilter => ", link)
+		t.Log("GoQueryLinksFilter => ", link.Href, link.Text)
 		return nil
 	}); err != nil {
 		panic(err)
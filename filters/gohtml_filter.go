@@ -5,10 +5,12 @@ import (
 	"github.com/themakers/simple-crawler/crawler"
 	"golang.org/x/net/html"
 	"io"
+	"strings"
 )
 
+// StreamingGoHTMLLinksFilter yields links of <a> elements with all the Link fields filled
 func StreamingGoHTMLLinksFilter() crawler.FilterFunc {
-	return func(ctx context.Context, r io.Reader, yieldTitle func(pos int, title string) error, yieldLink func(pos int, link string) error) error {
+	return func(ctx context.Context, r io.Reader, yieldTitle func(pos int, title string) error, yieldLink func(pos int, link crawler.Link) error) error {
 		getHref := func(t html.Token) (ok bool, href string) {
 			for _, a := range t.Attr {
 				if a.Key == "href" {
@@ -19,7 +21,24 @@ func StreamingGoHTMLLinksFilter() crawler.FilterFunc {
 			return
 		}
 
-		z := html.NewTokenizer(r)
+		var (
+			z = html.NewTokenizer(r)
+
+			// The <a> being read, if any; the link is yielded once its text is read
+			anchor *crawler.Link
+			text   strings.Builder
+		)
+
+		flush := func() error {
+			if anchor == nil {
+				return nil
+			}
+			anchor.Text = strings.Join(strings.Fields(text.String()), " ")
+			link := *anchor
+			anchor = nil
+			text.Reset()
+			return yieldLink(-1, link)
+		}
 
 		for {
 			tt := z.Next()
@@ -27,7 +46,17 @@ func StreamingGoHTMLLinksFilter() crawler.FilterFunc {
 			switch {
 			case tt == html.ErrorToken:
 				// End of the document, we're done
-				return nil
+				return flush()
+			case tt == html.TextToken:
+				if anchor != nil {
+					text.Write(z.Text())
+				}
+			case tt == html.EndTagToken:
+				if name, _ := z.TagName(); string(name) == "a" {
+					if err := flush(); err != nil {
+						return err
+					}
+				}
 			case tt == html.StartTagToken:
 				t := z.Token()
 
@@ -37,15 +66,23 @@ func StreamingGoHTMLLinksFilter() crawler.FilterFunc {
 					continue
 				}
 
+				//> Anchors can't be nested, so an unclosed one ends here
+				if err := flush(); err != nil {
+					return err
+				}
+
 				// Extract the href value, if there is one
 				ok, url := getHref(t)
 				if !ok {
 					continue
 				}
 
-				if err := yieldLink(-1, url); err != nil {
-					return err
+				anchor = &crawler.Link{Href: url, Tag: t.Data, Attrs: map[string]string{}}
+				for _, a := range t.Attr {
+					anchor.Attrs[a.Key] = a.Val
 				}
+				anchor.Title = anchor.Attrs["title"]
+				anchor.Rel = anchor.Attrs["rel"]
 			}
 		}
 	}
@@ -5,18 +5,32 @@ import (
 	"github.com/PuerkitoBio/goquery"
 	"github.com/themakers/simple-crawler/crawler"
 	"io"
+	"strings"
 )
 
+// GoQueryLinksFilter yields links of <a> elements with all the Link fields filled
 func GoQueryLinksFilter() crawler.FilterFunc {
-	return func(ctx context.Context, r io.Reader, yieldTitle func(pos int, title string) error, yieldLink func(pos int, link string) error) (err error) {
+	return func(ctx context.Context, r io.Reader, yieldTitle func(pos int, title string) error, yieldLink func(pos int, link crawler.Link) error) (err error) {
 		doc, err := goquery.NewDocumentFromReader(r)
 		if err != nil {
 			return err
 		}
 
-		doc.Filter("a").Each(func(i int, sel *goquery.Selection) {
+		doc.Find("a").Each(func(i int, sel *goquery.Selection) {
 			if val, ok := sel.Attr("href"); ok {
-				if e := yieldLink(-1, val); err != nil {
+				link := crawler.Link{
+					Href:  val,
+					Text:  strings.Join(strings.Fields(sel.Text()), " "),
+					Title: sel.AttrOr("title", ""),
+					Rel:   sel.AttrOr("rel", ""),
+					Tag:   goquery.NodeName(sel),
+					Attrs: map[string]string{},
+				}
+				for _, a := range sel.Nodes[0].Attr {
+					link.Attrs[a.Key] = a.Val
+				}
+
+				if e := yieldLink(-1, link); e != nil && err == nil {
 					err = e
 				}
 			}
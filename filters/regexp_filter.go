@@ -11,8 +11,9 @@ import (
 var filterRx = regexp.MustCompile(`<a\s+(?:[^>]*?\s+)?href="([^"]*)"`)
 
 // Do not need to implement streaming capabilities in RegExp filter, because it does not worth it, it's not performant.
+// Links of <a> elements are yielded with Href and Tag only
 func RegexpLinksFilter() crawler.FilterFunc {
-	return func(ctx context.Context, r io.Reader, yieldTitle func(pos int, title string) error, yieldLink func(pos int, link string) error) error {
+	return func(ctx context.Context, r io.Reader, yieldTitle func(pos int, title string) error, yieldLink func(pos int, link crawler.Link) error) error {
 		data, err := ioutil.ReadAll(r)
 		if err != nil {
 			return err
@@ -23,7 +24,7 @@ func RegexpLinksFilter() crawler.FilterFunc {
 		match := filterRx.FindAllStringSubmatchIndex(str, -1)
 
 		for _, match := range match {
-			if err := yieldLink(match[2], str[match[2]:match[3]]); err != nil {
+			if err := yieldLink(match[2], crawler.Link{Href: str[match[2]:match[3]], Tag: "a"}); err != nil {
 				return err
 			}
 		}
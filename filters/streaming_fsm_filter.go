@@ -12,6 +12,7 @@ import (
 
 // FIXME Strange behaviour on larger chunks
 // FIXME Strange behaviour on smaller chunks
+// href values of any element are yielded as links with Href only; use StreamingGoHTMLLinksFilter to get anchor text and attributes
 func StreamingFSMLinksFilter(chunkSize int) crawler.FilterFunc {
 	// FIXME Pool optimization does not work!
 	var pool sync.Pool
@@ -19,7 +20,7 @@ func StreamingFSMLinksFilter(chunkSize int) crawler.FilterFunc {
 		return make([]byte, 0, 32*1024) //> Average page size on the internet, IMO
 	}
 
-	return func(ctx context.Context, r io.Reader, yieldTitle func(pos int, title string) error, yieldLink func(pos int, link string) error) error {
+	return func(ctx context.Context, r io.Reader, yieldTitle func(pos int, title string) error, yieldLink func(pos int, link crawler.Link) error) error {
 		if chunkSize < 1024 {
 			chunkSize = 1024
 		}
@@ -204,7 +205,7 @@ func StreamingFSMLinksFilter(chunkSize int) crawler.FilterFunc {
 				} else if hrefValuePos >= 0 {
 
 					if end := findHrefValueEnd(); end >= 0 {
-						if err := yieldLink(readTotal-len(str)+hrefValuePos, str[:end]); err != nil {
+						if err := yieldLink(readTotal-len(str)+hrefValuePos, crawler.Link{Href: str[:end]}); err != nil {
 							return err
 						}
 
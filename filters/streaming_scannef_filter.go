@@ -9,7 +9,7 @@ import (
 
 // Not going to finish it, but it's performance is reasonable, but memory consumption is near to zero
 func StreamingScannerLinksFilter() crawler.FilterFunc {
-	return func(ctx context.Context, r io.Reader, yieldTitle func(pos int, title string) error, yieldLink func(pos int, link string) error) error {
+	return func(ctx context.Context, r io.Reader, yieldTitle func(pos int, title string) error, yieldLink func(pos int, link crawler.Link) error) error {
 
 		var s scanner.Scanner
 		s.Init(r)
@@ -0,0 +1,154 @@
+package keyed_pool
+
+import (
+	"sync"
+	"time"
+)
+
+// AIMD adjusts limits of the Semaphore keys the way TCP adjusts its window:
+// a key's limit is increased by one once as many acquisitions as the limit succeed,
+// and is cut down on failures and when latency grows above the usual one
+type AIMD struct {
+	sem *Semaphore
+
+	// Bounds of the limits; the limit starts at Min
+	Min int
+	Max LimitFunc
+
+	// Limit is multiplied by it on decrease; zero for 0.5
+	Decrease float64
+
+	// Latency this many times as high as the average one is a failure; zero for 3
+	LatencyFactor float64
+
+	lock sync.Mutex
+	keys map[string]*aimdKey
+}
+
+type aimdKey struct {
+	limit     int
+	successes int
+
+	// Responses to skip before another decrease, as those were in flight when the limit was decreased
+	cooldown int
+
+	// Exponentially weighted moving average
+	latency time.Duration
+}
+
+const aimdLatencyWeight = 0.2
+
+func NewAIMD(sem *Semaphore, min int, max LimitFunc) *AIMD {
+	if min < 1 {
+		min = 1
+	}
+
+	return &AIMD{
+		sem:  sem,
+		Min:  min,
+		Max:  max,
+		keys: map[string]*aimdKey{},
+	}
+}
+
+func (a *AIMD) key(key string) *aimdKey {
+	k, ok := a.keys[key]
+	if !ok {
+		k = &aimdKey{limit: a.Min}
+		a.keys[key] = k
+		a.sem.SetLimit(key, k.limit)
+	}
+	return k
+}
+
+// Start sets the initial limit of the key unless the key is known already; call it before the first acquisition
+func (a *AIMD) Start(key string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.key(key)
+}
+
+// Success reports an acquisition of the key done in time
+func (a *AIMD) Success(key string, latency time.Duration) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	k := a.key(key)
+
+	if k.cooldown > 0 {
+		k.cooldown--
+	}
+
+	factor := a.LatencyFactor
+	if factor == 0 {
+		factor = 3
+	}
+
+	if k.latency != 0 && float64(latency) > float64(k.latency)*factor {
+		a.decrease(key, k)
+		//> Don't let outliers shift the average
+		return
+	}
+
+	if k.latency == 0 {
+		k.latency = latency
+	} else {
+		k.latency = time.Duration(float64(k.latency)*(1-aimdLatencyWeight) + float64(latency)*aimdLatencyWeight)
+	}
+
+	k.successes++
+	if k.successes >= k.limit {
+		k.successes = 0
+		if max := a.Max(key); k.limit < max {
+			k.limit++
+			a.sem.SetLimit(key, k.limit)
+		}
+	}
+}
+
+// Failure reports an acquisition of the key failed because the resource is overloaded, e.g. timed out
+func (a *AIMD) Failure(key string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	k := a.key(key)
+
+	if k.cooldown > 0 {
+		k.cooldown--
+	}
+
+	a.decrease(key, k)
+}
+
+func (a *AIMD) decrease(key string, k *aimdKey) {
+	k.successes = 0
+
+	if k.cooldown > 0 {
+		return
+	}
+
+	factor := a.Decrease
+	if factor == 0 {
+		factor = 0.5
+	}
+
+	k.cooldown = k.limit
+	k.limit = int(float64(k.limit) * factor)
+	if k.limit < a.Min {
+		k.limit = a.Min
+	}
+
+	a.sem.SetLimit(key, k.limit)
+}
+
+// Limit tells the current limit of the key
+func (a *AIMD) Limit(key string) int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if k, ok := a.keys[key]; ok {
+		return k.limit
+	}
+	return a.Min
+}
@@ -0,0 +1,52 @@
+package keyed_pool
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAIMD(t *testing.T) {
+	sem := NewSemaphore(100)
+	a := NewAIMD(sem, 1, func(key string) int {
+		return 4
+	})
+
+	a.Start("1")
+	if limit := sem.Limit("1"); limit != 1 {
+		t.Log("bad initial limit; actual", limit, "expected", 1)
+		t.Fail()
+	}
+
+	//> 1 success raises 1 to 2, 2 more raise it to 3, then 3 more to 4, and it stays at max
+	for i := 0; i < 10; i++ {
+		a.Success("1", 10*time.Millisecond)
+	}
+	if limit := sem.Limit("1"); limit != 4 {
+		t.Log("bad increased limit; actual", limit, "expected", 4)
+		t.Fail()
+	}
+
+	a.Failure("1")
+	if limit := sem.Limit("1"); limit != 2 {
+		t.Log("bad decreased limit; actual", limit, "expected", 2)
+		t.Fail()
+	}
+
+	//> Requests which were in flight before the decrease don't decrease it again
+	a.Failure("1")
+	if limit := sem.Limit("1"); limit != 2 {
+		t.Log("bad limit after a failure in flight; actual", limit, "expected", 2)
+		t.Fail()
+	}
+
+	for i := 0; i < 4; i++ {
+		a.Success("1", 10*time.Millisecond)
+	}
+
+	//> Rising latency is a failure too
+	a.Success("1", 100*time.Millisecond)
+	if limit := sem.Limit("1"); limit != 1 {
+		t.Log("bad limit after a slow response; actual", limit, "expected", 1)
+		t.Fail()
+	}
+}
@@ -1,39 +1,19 @@
 package keyed_pool
 
-import "sync"
-
-// TODO make Waiter a base method to make code more well-organized
+import "context"
 
 type EnqueueFunc func(key string, fn func())
 type WaiterFunc func(key string) func()
 
-func Pool(threadsPerKey int) EnqueueFunc {
-	var (
-		cond = sync.NewCond(new(sync.Mutex))
+// WaitContextFunc acquires a slot of the key; returns the context error if the context is done before that
+type WaitContextFunc func(ctx context.Context, key string) (release func(), err error)
 
-		keys = map[string]int{}
-	)
+func Pool(threadsPerKey int) EnqueueFunc {
+	s := NewSemaphore(threadsPerKey)
 
 	return func(key string, fn func()) {
-		defer cond.Broadcast()
-
-		defer func() {
-			cond.L.Lock()
-			defer cond.L.Unlock()
-
-			keys[key]--
-			if keys[key] == 0 {
-				delete(keys, key)
-			}
-		}()
-
-		cond.L.Lock()
-		for keys[key] >= threadsPerKey {
-			cond.Wait()
-		}
-
-		keys[key]++
-		cond.L.Unlock()
+		_ = s.Acquire(context.Background(), key) //> Never fails without a context
+		defer s.Release(key)
 
 		if fn != nil {
 			fn()
@@ -42,23 +22,27 @@ func Pool(threadsPerKey int) EnqueueFunc {
 }
 
 func Waiter(threadsPerKey int) WaiterFunc {
-	do := Pool(threadsPerKey)
+	s := NewSemaphore(threadsPerKey)
 
 	return func(key string) func() {
-		var (
-			c1 = make(chan struct{})
-			c2 = make(chan struct{})
-		)
+		_ = s.Acquire(context.Background(), key)
 
-		go do(key, func() {
-			close(c1)
-			<-c2
-		})
+		return func() {
+			s.Release(key)
+		}
+	}
+}
 
-		<-c1
+func WaiterContext(threadsPerKey int) WaitContextFunc {
+	s := NewSemaphore(threadsPerKey)
 
-		return func() {
-			close(c2)
+	return func(ctx context.Context, key string) (func(), error) {
+		if err := s.Acquire(ctx, key); err != nil {
+			return nil, err
 		}
+
+		return func() {
+			s.Release(key)
+		}, nil
 	}
 }
@@ -132,3 +132,36 @@ func BenchmarkGoroutineAndContextOverhead(b *testing.B) {
 		<-ctx.Done()
 	}
 }
+
+func TestWaiterContext(t *testing.T) {
+	wait := WaiterContext(1)
+
+	release, err := wait(context.Background(), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := wait(ctx, "1"); err != context.DeadlineExceeded {
+		t.Log("bad error of a busy key; actual", err, "expected", context.DeadlineExceeded)
+		t.Fail()
+	}
+
+	if release2, err := wait(context.Background(), "2"); err != nil {
+		t.Log("unexpected error of a free key", err)
+		t.Fail()
+	} else {
+		release2()
+	}
+
+	release()
+
+	if release, err := wait(context.Background(), "1"); err != nil {
+		t.Log("unexpected error of a released key", err)
+		t.Fail()
+	} else {
+		release()
+	}
+}
@@ -0,0 +1,321 @@
+package keyed_pool
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// LimitFunc tells the number of slots of a key
+type LimitFunc func(key string) int
+
+// Semaphore limits the number of slots held per key and, optionally, in total.
+// Waiters of a key are served in order of priority, then in FIFO order, and a release wakes up only the waiters it hands slots to.
+// When the total limit is reached, keys take turns in round-robin order as slots are released
+type Semaphore struct {
+	defaultLimit LimitFunc
+
+	lock     sync.Mutex
+	keys     map[string]*semaphoreKey
+	limits   map[string]int
+	counters map[string]*semaphoreCounters
+
+	totalLimit int
+	totalHeld  int
+
+	// Keys whose first waiter fits the key limit and waits for the total one
+	ready list.List // of *semaphoreKey
+}
+
+type semaphoreKey struct {
+	name    string
+	limit   int
+	held    int
+	waiters list.List // of *semaphoreWaiter
+
+	readyElem *list.Element
+	counters  *semaphoreCounters
+}
+
+// semaphoreCounters outlive semaphoreKey, which is dropped once the key is not in use
+type semaphoreCounters struct {
+	acquired int64
+	waited   time.Duration
+}
+
+type semaphoreWaiter struct {
+	n        int
+	priority int
+	since    time.Time
+	ready    chan struct{} //> Closed once the slots are handed over
+}
+
+func NewSemaphore(limitPerKey int) *Semaphore {
+	return NewSemaphoreFunc(func(key string) int {
+		return limitPerKey
+	})
+}
+
+// NewSemaphoreFunc creates a Semaphore with limits of keys told by defaultLimit unless set with SetLimit
+func NewSemaphoreFunc(defaultLimit LimitFunc) *Semaphore {
+	return &Semaphore{
+		defaultLimit: defaultLimit,
+		keys:         map[string]*semaphoreKey{},
+		limits:       map[string]int{},
+		counters:     map[string]*semaphoreCounters{},
+	}
+}
+
+func (s *Semaphore) key(key string) *semaphoreKey {
+	k, ok := s.keys[key]
+	if !ok {
+		counters, ok := s.counters[key]
+		if !ok {
+			counters = &semaphoreCounters{}
+			s.counters[key] = counters
+		}
+
+		k = &semaphoreKey{name: key, limit: s.limit(key), counters: counters}
+		s.keys[key] = k
+	}
+	return k
+}
+
+func (s *Semaphore) limit(key string) int {
+	if limit, ok := s.limits[key]; ok {
+		return limit
+	}
+	return s.defaultLimit(key)
+}
+
+// SetLimit overrides the limit of the key; zero limit blocks the key.
+// Waiters are let in right away if the limit is raised, slots held above a lowered limit are taken back as they are released
+func (s *Semaphore) SetLimit(key string, limit int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.limits[key] = limit
+
+	if k, ok := s.keys[key]; ok {
+		k.limit = limit
+		s.update(k)
+		s.schedule()
+	}
+}
+
+// ResetLimit drops the override of SetLimit
+func (s *Semaphore) ResetLimit(key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.limits, key)
+
+	if k, ok := s.keys[key]; ok {
+		k.limit = s.defaultLimit(key)
+		s.update(k)
+		s.schedule()
+	}
+}
+
+// Limit tells the current limit of the key
+func (s *Semaphore) Limit(key string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if k, ok := s.keys[key]; ok {
+		return k.limit
+	}
+	return s.limit(key)
+}
+
+// SetTotalLimit limits slots held across all the keys; zero for no limit
+func (s *Semaphore) SetTotalLimit(limit int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.totalLimit = limit
+	s.schedule()
+}
+
+func (s *Semaphore) TotalLimit() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.totalLimit
+}
+
+// Acquire blocks until a slot of the key is available or the context is done
+func (s *Semaphore) Acquire(ctx context.Context, key string) error {
+	return s.AcquireN(ctx, key, 1)
+}
+
+// AcquireN acquires n slots of the key at once. Acquisition of more slots than a limit
+// waits until nothing is held under that limit
+func (s *Semaphore) AcquireN(ctx context.Context, key string, n int) error {
+	return s.acquire(ctx, key, n, 0)
+}
+
+// AcquirePriority acquires a slot of the key ahead of the waiters of lower priority; Acquire is of zero priority.
+// Priorities are not compared across keys
+func (s *Semaphore) AcquirePriority(ctx context.Context, key string, priority int) error {
+	return s.acquire(ctx, key, 1, priority)
+}
+
+func (s *Semaphore) acquire(ctx context.Context, key string, n, priority int) error {
+	s.lock.Lock()
+
+	k := s.key(key)
+
+	if s.available(k, n) {
+		s.take(k, n)
+		s.lock.Unlock()
+		return nil
+	}
+
+	w := &semaphoreWaiter{n: n, priority: priority, since: time.Now(), ready: make(chan struct{})}
+	elem := k.enqueue(w)
+	s.update(k)
+
+	s.lock.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	select {
+	case <-w.ready:
+		//> The slots were handed over concurrently with cancellation, pass them on
+		s.release(k, n)
+	default:
+		k.waiters.Remove(elem)
+		//> Smaller acquisitions could wait behind this one
+		s.update(k)
+		s.schedule()
+		s.cleanup(k)
+	}
+
+	return ctx.Err()
+}
+
+// TryAcquire acquires a slot of the key only if it's available right away
+func (s *Semaphore) TryAcquire(key string) bool {
+	return s.TryAcquireN(key, 1)
+}
+
+func (s *Semaphore) TryAcquireN(key string, n int) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	k := s.key(key)
+
+	if s.available(k, n) {
+		s.take(k, n)
+		return true
+	}
+
+	s.cleanup(k)
+	return false
+}
+
+// Release releases a slot of the key acquired before
+func (s *Semaphore) Release(key string) {
+	s.ReleaseN(key, 1)
+}
+
+func (s *Semaphore) ReleaseN(key string, n int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	k, ok := s.keys[key]
+	if !ok || k.held < n {
+		panic("keyed_pool: release of a key not acquired")
+	}
+
+	s.release(k, n)
+}
+
+func (s *Semaphore) release(k *semaphoreKey, n int) {
+	k.held -= n
+	s.totalHeld -= n
+
+	s.update(k)
+	s.schedule()
+	s.cleanup(k)
+}
+
+// available tells if n slots of the key can be taken without cutting in line
+func (s *Semaphore) available(k *semaphoreKey, n int) bool {
+	return k.waiters.Len() == 0 && k.fits(n) && s.ready.Len() == 0 && s.fitsTotal(n)
+}
+
+func (s *Semaphore) take(k *semaphoreKey, n int) {
+	k.held += n
+	s.totalHeld += n
+	k.counters.acquired++
+}
+
+// update puts the key in the ready ring if its first waiter fits the key limit, or takes it out otherwise
+func (s *Semaphore) update(k *semaphoreKey) {
+	fits := k.waiters.Len() > 0 && k.fits(k.waiters.Front().Value.(*semaphoreWaiter).n)
+
+	if fits && k.readyElem == nil {
+		k.readyElem = s.ready.PushBack(k)
+	} else if !fits && k.readyElem != nil {
+		s.ready.Remove(k.readyElem)
+		k.readyElem = nil
+	}
+}
+
+// schedule hands slots over to the first waiters of ready keys in turn while they fit the total limit
+func (s *Semaphore) schedule() {
+	for s.ready.Len() > 0 {
+		k := s.ready.Front().Value.(*semaphoreKey)
+		w := k.waiters.Front().Value.(*semaphoreWaiter)
+
+		if !s.fitsTotal(w.n) {
+			return
+		}
+
+		s.ready.Remove(k.readyElem)
+		k.readyElem = nil
+
+		k.waiters.Remove(k.waiters.Front())
+		s.take(k, w.n)
+		k.counters.waited += time.Since(w.since)
+		close(w.ready)
+
+		//> To the end of the ring if there are more waiters to let in
+		s.update(k)
+	}
+}
+
+func (s *Semaphore) cleanup(k *semaphoreKey) {
+	if k.held == 0 && k.waiters.Len() == 0 && s.keys[k.name] == k {
+		delete(s.keys, k.name)
+	}
+}
+
+func (s *Semaphore) fitsTotal(n int) bool {
+	return s.totalLimit <= 0 || s.totalHeld+n <= s.totalLimit || s.totalHeld == 0
+}
+
+// enqueue puts the waiter after the ones of the same or higher priority
+func (k *semaphoreKey) enqueue(w *semaphoreWaiter) *list.Element {
+	//> Usually all the waiters are of the same priority, so look from the back
+	for e := k.waiters.Back(); e != nil; e = e.Prev() {
+		if e.Value.(*semaphoreWaiter).priority >= w.priority {
+			return k.waiters.InsertAfter(w, e)
+		}
+	}
+	return k.waiters.PushFront(w)
+}
+
+func (k *semaphoreKey) fits(n int) bool {
+	return k.held+n <= k.limit || (k.held == 0 && k.limit > 0)
+}
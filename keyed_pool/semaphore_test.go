@@ -0,0 +1,330 @@
+package keyed_pool
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSemaphoreFIFO(t *testing.T) {
+	s := NewSemaphore(1)
+
+	if err := s.Acquire(context.Background(), "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		order     []int
+		orderLock sync.Mutex
+		wg        sync.WaitGroup
+	)
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			if err := s.Acquire(context.Background(), "1"); err != nil {
+				t.Error(err)
+				return
+			}
+
+			orderLock.Lock()
+			order = append(order, i)
+			orderLock.Unlock()
+
+			s.Release("1")
+		}(i)
+
+		//> Let it get in the queue
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	s.Release("1")
+	wg.Wait()
+
+	if expected := []int{0, 1, 2, 3, 4}; !reflect.DeepEqual(order, expected) {
+		t.Log("bad order; actual", order, "expected", expected)
+		t.Fail()
+	}
+
+	if len(s.keys) != 0 {
+		t.Log("keys are not cleaned up; actual", len(s.keys))
+		t.Fail()
+	}
+}
+
+func TestSemaphoreCancel(t *testing.T) {
+	s := NewSemaphore(1)
+
+	if err := s.Acquire(context.Background(), "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() {
+		done <- s.Acquire(ctx, "1")
+	}()
+
+	time.Sleep(5 * time.Millisecond)
+	cancel()
+
+	if err := <-done; err != context.Canceled {
+		t.Log("bad error; actual", err, "expected", context.Canceled)
+		t.Fail()
+	}
+
+	//> The canceled waiter must not take the slot
+	s.Release("1")
+	if !s.TryAcquire("1") {
+		t.Log("slot is lost")
+		t.Fail()
+	}
+}
+
+// condWaiter is the Waiter implementation before Semaphore, kept for comparison
+func condWaiter(threadsPerKey int) WaiterFunc {
+	var (
+		cond = sync.NewCond(new(sync.Mutex))
+		keys = map[string]int{}
+	)
+
+	do := func(key string, fn func()) {
+		defer cond.Broadcast()
+
+		defer func() {
+			cond.L.Lock()
+			defer cond.L.Unlock()
+
+			keys[key]--
+			if keys[key] == 0 {
+				delete(keys, key)
+			}
+		}()
+
+		cond.L.Lock()
+		for keys[key] >= threadsPerKey {
+			cond.Wait()
+		}
+
+		keys[key]++
+		cond.L.Unlock()
+
+		fn()
+	}
+
+	return func(key string) func() {
+		var (
+			c1 = make(chan struct{})
+			c2 = make(chan struct{})
+		)
+
+		go do(key, func() {
+			close(c1)
+			<-c2
+		})
+
+		<-c1
+
+		return func() {
+			close(c2)
+		}
+	}
+}
+
+func benchmarkWaiterManyKeys(b *testing.B, wait WaiterFunc, keys int) {
+	var wg sync.WaitGroup
+
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			release := wait(strconv.Itoa(n % keys))
+			release()
+		}(n)
+	}
+
+	wg.Wait()
+}
+
+func BenchmarkCondWaiter1000Keys(b *testing.B) {
+	benchmarkWaiterManyKeys(b, condWaiter(2), 1000)
+}
+
+func BenchmarkWaiter1000Keys(b *testing.B) {
+	benchmarkWaiterManyKeys(b, Waiter(2), 1000)
+}
+
+func BenchmarkCondWaiter10000Keys(b *testing.B) {
+	benchmarkWaiterManyKeys(b, condWaiter(2), 10000)
+}
+
+func BenchmarkWaiter10000Keys(b *testing.B) {
+	benchmarkWaiterManyKeys(b, Waiter(2), 10000)
+}
+
+func TestSemaphoreLimits(t *testing.T) {
+	s := NewSemaphoreFunc(func(key string) int {
+		if key == "cdn" {
+			return 3
+		}
+		return 1
+	})
+
+	if !s.TryAcquire("small") || s.TryAcquire("small") {
+		t.Log("bad default limit of small")
+		t.Fail()
+	}
+
+	if !s.TryAcquireN("cdn", 2) || !s.TryAcquire("cdn") || s.TryAcquire("cdn") {
+		t.Log("bad default limit of cdn")
+		t.Fail()
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- s.AcquireN(context.Background(), "small", 2)
+	}()
+
+	time.Sleep(5 * time.Millisecond)
+
+	//> Raising the limit lets the waiter in without a release
+	s.SetLimit("small", 3)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter is not let in after the limit is raised")
+	}
+
+	if s.TryAcquire("small") {
+		t.Log("bad raised limit")
+		t.Fail()
+	}
+
+	s.ReleaseN("small", 3)
+
+	//> The override outlives the key
+	if limit := s.Limit("small"); limit != 3 {
+		t.Log("bad limit; actual", limit, "expected", 3)
+		t.Fail()
+	}
+
+	s.ResetLimit("small")
+	if limit := s.Limit("small"); limit != 1 {
+		t.Log("bad reset limit; actual", limit, "expected", 1)
+		t.Fail()
+	}
+
+	//> More than the limit is acquired once the key is free
+	if !s.TryAcquireN("small", 5) {
+		t.Log("bad acquisition above the limit")
+		t.Fail()
+	}
+}
+
+func TestSemaphoreTotalLimit(t *testing.T) {
+	s := NewSemaphore(10)
+	s.SetTotalLimit(1)
+
+	if !s.TryAcquire("x") || s.TryAcquire("y") {
+		t.Fatal("bad total limit")
+	}
+
+	var (
+		order     []string
+		orderLock sync.Mutex
+		wg        sync.WaitGroup
+	)
+
+	for _, name := range []string{"a1", "a2", "a3", "b1"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+
+			key := name[:1]
+			if err := s.Acquire(context.Background(), key); err != nil {
+				t.Error(err)
+				return
+			}
+
+			orderLock.Lock()
+			order = append(order, name)
+			orderLock.Unlock()
+
+			s.Release(key)
+		}(name)
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	s.Release("x")
+	wg.Wait()
+
+	//> Keys take turns rather than the first one taking all the slots
+	if expected := []string{"a1", "b1", "a2", "a3"}; !reflect.DeepEqual(order, expected) {
+		t.Log("bad order; actual", order, "expected", expected)
+		t.Fail()
+	}
+}
+
+func TestSemaphorePriority(t *testing.T) {
+	s := NewSemaphore(1)
+
+	if !s.TryAcquire("1") {
+		t.Fatal("bad limit")
+	}
+
+	var (
+		order     []string
+		orderLock sync.Mutex
+		wg        sync.WaitGroup
+	)
+
+	for _, w := range []struct {
+		name     string
+		priority int
+	}{
+		{"deep1", -2},
+		{"normal1", 0},
+		{"deep2", -2},
+		{"urgent", 1},
+		{"normal2", 0},
+	} {
+		wg.Add(1)
+		go func(name string, priority int) {
+			defer wg.Done()
+
+			if err := s.AcquirePriority(context.Background(), "1", priority); err != nil {
+				t.Error(err)
+				return
+			}
+
+			orderLock.Lock()
+			order = append(order, name)
+			orderLock.Unlock()
+
+			s.Release("1")
+		}(w.name, w.priority)
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	s.Release("1")
+	wg.Wait()
+
+	if expected := []string{"urgent", "normal1", "normal2", "deep1", "deep2"}; !reflect.DeepEqual(order, expected) {
+		t.Log("bad order; actual", order, "expected", expected)
+		t.Fail()
+	}
+}
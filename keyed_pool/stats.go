@@ -0,0 +1,110 @@
+package keyed_pool
+
+import (
+	"sort"
+	"time"
+)
+
+type KeyStats struct {
+	Key string
+
+	// Slots held and acquisitions waiting at the moment
+	InFlight int
+	Waiting  int
+
+	// Acquisitions done since the Semaphore was created, and the time they waited in total
+	Acquired int64
+	WaitTime time.Duration
+}
+
+// AvgWait is the average time an acquisition of the key waited for a slot
+func (ks KeyStats) AvgWait() time.Duration {
+	if ks.Acquired == 0 {
+		return 0
+	}
+	return ks.WaitTime / time.Duration(ks.Acquired)
+}
+
+// Stats sums up all the keys
+type Stats struct {
+	InFlight int
+	Waiting  int
+	Acquired int64
+	WaitTime time.Duration
+
+	// Keys ever acquired
+	Keys int
+}
+
+func (s *Semaphore) keyStats(key string, counters *semaphoreCounters) KeyStats {
+	ks := KeyStats{
+		Key:      key,
+		Acquired: counters.acquired,
+		WaitTime: counters.waited,
+	}
+
+	if k, ok := s.keys[key]; ok {
+		ks.InFlight = k.held
+		ks.Waiting = k.waiters.Len()
+	}
+
+	return ks
+}
+
+func (s *Semaphore) KeyStats(key string) KeyStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if counters, ok := s.counters[key]; ok {
+		return s.keyStats(key, counters)
+	}
+	return KeyStats{Key: key}
+}
+
+func (s *Semaphore) Stats() Stats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stats := Stats{
+		InFlight: s.totalHeld,
+		Keys:     len(s.counters),
+	}
+
+	for _, k := range s.keys {
+		stats.Waiting += k.waiters.Len()
+	}
+	for _, counters := range s.counters {
+		stats.Acquired += counters.acquired
+		stats.WaitTime += counters.waited
+	}
+
+	return stats
+}
+
+// Top returns n busiest keys: by acquisitions in flight and waiting, then by total wait time
+func (s *Semaphore) Top(n int) []KeyStats {
+	s.lock.Lock()
+
+	all := make([]KeyStats, 0, len(s.counters))
+	for key, counters := range s.counters {
+		all = append(all, s.keyStats(key, counters))
+	}
+
+	s.lock.Unlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		a, b := all[i], all[j]
+		if a.InFlight+a.Waiting != b.InFlight+b.Waiting {
+			return a.InFlight+a.Waiting > b.InFlight+b.Waiting
+		}
+		if a.WaitTime != b.WaitTime {
+			return a.WaitTime > b.WaitTime
+		}
+		return a.Key < b.Key
+	})
+
+	if len(all) > n {
+		all = all[:n]
+	}
+	return all
+}
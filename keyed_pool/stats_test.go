@@ -0,0 +1,58 @@
+package keyed_pool
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSemaphoreStats(t *testing.T) {
+	const delta = 20 * time.Millisecond
+
+	s := NewSemaphore(1)
+
+	if !s.TryAcquire("busy") || !s.TryAcquire("idle") {
+		t.Fatal("bad limit")
+	}
+	s.Release("idle")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := s.Acquire(context.Background(), "busy"); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	time.Sleep(delta)
+
+	if ks := s.KeyStats("busy"); ks.InFlight != 1 || ks.Waiting != 1 || ks.Acquired != 1 {
+		t.Log("bad stats of a waited key; actual", ks)
+		t.Fail()
+	}
+
+	s.Release("busy")
+	<-done
+
+	ks := s.KeyStats("busy")
+	if ks.InFlight != 1 || ks.Waiting != 0 || ks.Acquired != 2 || ks.WaitTime < delta || ks.AvgWait() < delta/2 {
+		t.Log("bad stats of a released key; actual", ks)
+		t.Fail()
+	}
+
+	if stats := s.Stats(); stats.InFlight != 1 || stats.Waiting != 0 || stats.Acquired != 3 || stats.Keys != 2 {
+		t.Log("bad total stats; actual", stats)
+		t.Fail()
+	}
+
+	if top := s.Top(1); len(top) != 1 || top[0].Key != "busy" {
+		t.Log("bad top; actual", top)
+		t.Fail()
+	}
+
+	//> Idle keys are still reported
+	if top := s.Top(10); len(top) != 2 || top[1].Key != "idle" || top[1].Acquired != 1 {
+		t.Log("bad top of all keys; actual", top)
+		t.Fail()
+	}
+}
@@ -0,0 +1,158 @@
+// Package psl implements Public Suffix List lookups (https://publicsuffix.org).
+// A copy of the list is embedded; fresher one may be loaded from a file.
+package psl
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"errors"
+	"golang.org/x/net/idna"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+//go:embed public_suffix_list.dat
+var embedded []byte
+
+type ruleKind uint8
+
+const (
+	ruleNormal ruleKind = 1 << iota
+	ruleWildcard
+	ruleException
+)
+
+type List struct {
+	//> Keyed by the rule without "*." and "!" prefixes
+	rules map[string]ruleKind
+}
+
+var ErrPublicSuffix = errors.New("host is a public suffix")
+
+var (
+	defaultList *List
+	defaultOnce sync.Once
+)
+
+// Default returns the embedded list
+func Default() *List {
+	defaultOnce.Do(func() {
+		l, err := Parse(bytes.NewReader(embedded))
+		if err != nil {
+			panic(err) //> Embedded list is known to be valid
+		}
+		defaultList = l
+	})
+	return defaultList
+}
+
+// LoadFile loads the list in the publicsuffix.org format, e.g. downloaded from https://publicsuffix.org/list/public_suffix_list.dat
+func LoadFile(path string) (*List, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+// Parse reads the list in the publicsuffix.org format. Both ICANN and private domains are used.
+func Parse(r io.Reader) (*List, error) {
+	l := &List{rules: map[string]ruleKind{}}
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		//> Rules end at the first whitespace
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "//") {
+			continue
+		}
+
+		rule, kind := strings.ToLower(fields[0]), ruleNormal
+		if strings.HasPrefix(rule, "!") {
+			rule, kind = rule[1:], ruleException
+		} else if strings.HasPrefix(rule, "*.") {
+			rule, kind = rule[2:], ruleWildcard
+		}
+
+		l.rules[rule] |= kind
+
+		//> Hosts are usually punycoded, but the list is in Unicode
+		if ascii, err := idna.ToASCII(rule); err == nil && ascii != rule {
+			l.rules[ascii] |= kind
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(l.rules) == 0 {
+		return nil, errors.New("public suffix list is empty")
+	}
+
+	return l, nil
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// PublicSuffix returns the effective TLD of the host, e.g. "co.uk" for "www.example.co.uk".
+// Unlisted TLDs are considered public suffixes too.
+func (l *List) PublicSuffix(host string) string {
+	host = normalizeHost(host)
+
+	for i := 0; ; {
+		rest := ""
+		if dot := strings.IndexByte(host[i:], '.'); dot >= 0 {
+			rest = host[i+dot+1:]
+		}
+
+		candidate := host[i:]
+
+		if l.rules[candidate]&ruleException != 0 {
+			return rest
+		}
+		if l.rules[candidate]&ruleNormal != 0 {
+			return candidate
+		}
+		if rest != "" && l.rules[rest]&ruleWildcard != 0 {
+			return candidate
+		}
+
+		if rest == "" {
+			//> The default "*" rule
+			return candidate
+		}
+
+		i = len(host) - len(rest)
+	}
+}
+
+// RegistrableDomain returns the effective TLD plus one label, e.g. "example.co.uk" for "www.example.co.uk".
+// IP addresses are returned as is.
+func (l *List) RegistrableDomain(host string) (string, error) {
+	if net.ParseIP(strings.Trim(host, "[]")) != nil {
+		return host, nil
+	}
+
+	host = normalizeHost(host)
+	suffix := l.PublicSuffix(host)
+
+	if len(host) <= len(suffix) {
+		return "", ErrPublicSuffix
+	}
+
+	prefix := host[:len(host)-len(suffix)-1]
+	if dot := strings.LastIndexByte(prefix, '.'); dot >= 0 {
+		prefix = prefix[dot+1:]
+	}
+
+	return prefix + "." + suffix, nil
+}
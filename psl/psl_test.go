@@ -0,0 +1,59 @@
+package psl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegistrableDomain(t *testing.T) {
+	for host, expected := range map[string]string{
+		"example.com":                   "example.com",
+		"www.example.com":               "example.com",
+		"blog.example.com.":             "example.com",
+		"WWW.Example.CO.UK":             "example.co.uk",
+		"en.wikipedia.org":              "wikipedia.org",
+		"foo.bar.github.io":             "bar.github.io",
+		"a.b.c.kawasaki.jp":             "b.c.kawasaki.jp",  //> *.kawasaki.jp
+		"www.city.kawasaki.jp":          "city.kawasaki.jp", //> !city.kawasaki.jp
+		"example.unlisted":              "example.unlisted",
+		"www.xn--85x722f.xn--55qx5d.cn": "xn--85x722f.xn--55qx5d.cn", //> 公司.cn
+		"127.0.0.1":                     "127.0.0.1",
+	} {
+		actual, err := Default().RegistrableDomain(host)
+		if err != nil || actual != expected {
+			t.Log("bad registrable domain of", host, "; actual", actual, err, "expected", expected)
+			t.Fail()
+		}
+	}
+
+	for _, host := range []string{"com", "co.uk", "github.io", "foo.kawasaki.jp"} {
+		if actual, err := Default().RegistrableDomain(host); err != ErrPublicSuffix {
+			t.Log("public suffix", host, "is not detected; actual", actual, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "list.dat")
+	if err := os.WriteFile(path, []byte("// comment\ncom\n*.example.com\n!www.example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for host, expected := range map[string]string{
+		"a.b.example.com": "a.b.example.com",
+		"www.example.com": "www.example.com",
+		"foo.org":         "foo.org",
+	} {
+		if actual, err := l.RegistrableDomain(host); err != nil || actual != expected {
+			t.Log("bad registrable domain of", host, "; actual", actual, err, "expected", expected)
+			t.Fail()
+		}
+	}
+}
@@ -41,10 +41,10 @@ func main() {
 
 		cr := crawler.New(
 			filters.StreamingFSMLinksFilter(1024),
-			func(depth, pos int, origin string, title string) {
-				//log.Printf("title found. origin = %s, pos = %d, title = %s;", origin, pos, title)
+			func(page *crawler.Page, pos int, title string) {
+				//log.Printf("title found. origin = %s, pos = %d, title = %s;", page.URL, pos, title)
 			},
-			func(depth, pos int, origin string, originalLink string, link *url.URL, external bool) bool {
+			func(page *crawler.Page, pos int, found crawler.Link, link *url.URL, rel crawler.Relation) bool {
 
 				link.Fragment = ""
 				linkKey := link.String()
@@ -59,18 +59,18 @@ func main() {
 						return false
 					} else {
 						crawled[linkKey] = true
-						//log.Printf("url found. external = %t; originalLink = %s; origin = %s, pos = %d, link = %s;", external, originalLink, origin, pos, linkText)
+						//log.Printf("url found. relation = %s; originalLink = %s; text = %q; origin = %s, pos = %d, link = %s;", rel, found.Href, found.Text, page.URL, pos, linkText)
 
 						atomic.StoreInt64(&tt, int64(time.Now().Sub(t0)))
 						return true
 					}
 				} else {
-					//log.Printf("data url found. origin = %s, pos = %d;", origin, pos)
+					//log.Printf("data url found. origin = %s, pos = %d;", page.URL, pos)
 					return false
 				}
-			}, func(origin, link string, pos int, err error) {
+			}, func(page *crawler.Page, link string, pos int, err error) {
 				if !strings.Contains(err.Error(), context.Canceled.Error()) {
-					log.Printf("error. origin = %s, link = %s, pos = %d, error = %v;", origin, link, pos, err)
+					log.Printf("error. origin = %s, link = %s, pos = %d, error = %v;", page.URL, link, pos, err)
 				}
 			},
 			crawler.Options{
@@ -0,0 +1,324 @@
+package urlnorm
+
+import (
+	"golang.org/x/net/idna"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Flags select normalizations to apply
+type Flags uint
+
+const (
+	LowercaseScheme Flags = 1 << iota
+	LowercaseHost
+
+	// Removes :80 from http:// and :443 from https:// URLs
+	RemoveDefaultPort
+
+	// Resolves "." and ".." path segments
+	RemoveDotSegments
+
+	// Decodes percent-encoded unreserved characters and uppercases hex digits of the rest
+	NormalizePercentEncoding
+
+	// Converts internationalized domain names to punycode
+	IDNToPunycode
+
+	// Turns empty path of http(s) URLs into "/"
+	AddRootPath
+
+	// Removes trailing "?" of an empty query
+	RemoveEmptyQuery
+
+	RemoveFragment
+
+	// Sorts query parameters; order of parameters with the same name is preserved
+	SortQuery
+
+	// Removes query parameters listed in Normalizer.TrackingParams
+	RemoveTrackingParams
+)
+
+const (
+	// Semantics preserving normalizations
+	Safe = LowercaseScheme | LowercaseHost | RemoveDefaultPort | RemoveDotSegments | NormalizePercentEncoding |
+		IDNToPunycode | AddRootPath | RemoveEmptyQuery
+
+	// Normalizations which do not preserve semantics in theory, but do in practice
+	UsuallySafe = Safe | RemoveFragment | SortQuery | RemoveTrackingParams
+)
+
+// Names ending with '*' are prefixes
+var DefaultTrackingParams = []string{
+	"utm_*",
+	"fbclid",
+	"gclid",
+	"dclid",
+	"msclkid",
+	"yclid",
+	"igshid",
+	"mc_cid",
+	"mc_eid",
+	"_ga",
+	"_hsenc",
+	"_hsmi",
+}
+
+type Normalizer struct {
+	Flags Flags
+
+	// Nil for DefaultTrackingParams
+	TrackingParams []string
+}
+
+var Default = &Normalizer{Flags: Safe}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+	"ftp":   "21",
+}
+
+// Normalize returns normalized copy of u
+func (n *Normalizer) Normalize(u *url.URL) *url.URL {
+	nu := *u
+	if u.User != nil {
+		user := *u.User
+		nu.User = &user
+	}
+
+	f := n.Flags
+
+	if f&LowercaseScheme != 0 {
+		nu.Scheme = strings.ToLower(nu.Scheme)
+	}
+
+	if nu.Host != "" {
+		host, port := splitHostPort(nu.Host)
+
+		if f&LowercaseHost != 0 {
+			host = strings.ToLower(host)
+		}
+
+		if f&IDNToPunycode != 0 && !strings.HasPrefix(host, "[") {
+			if ascii, err := idna.ToASCII(host); err == nil {
+				host = ascii
+			}
+		}
+
+		if f&RemoveDefaultPort != 0 && port != "" && defaultPorts[strings.ToLower(nu.Scheme)] == port {
+			port = ""
+		}
+
+		if port != "" {
+			nu.Host = host + ":" + port
+		} else {
+			nu.Host = host
+		}
+	}
+
+	if nu.Opaque == "" {
+		path := nu.EscapedPath()
+
+		if f&NormalizePercentEncoding != 0 {
+			path = normalizePercentEncoding(path)
+		}
+
+		if f&RemoveDotSegments != 0 {
+			path = removeDotSegments(path)
+		}
+
+		if f&AddRootPath != 0 && path == "" && nu.Host != "" && (nu.Scheme == "http" || nu.Scheme == "https") {
+			path = "/"
+		}
+
+		if unescaped, err := url.PathUnescape(path); err == nil {
+			nu.Path = unescaped
+			nu.RawPath = path
+		}
+	}
+
+	if nu.RawQuery != "" && f&(NormalizePercentEncoding|SortQuery|RemoveTrackingParams) != 0 {
+		nu.RawQuery = n.normalizeQuery(nu.RawQuery)
+	}
+
+	if f&RemoveEmptyQuery != 0 && nu.RawQuery == "" {
+		nu.ForceQuery = false
+	}
+
+	if f&RemoveFragment != 0 {
+		nu.Fragment = ""
+		nu.RawFragment = ""
+	}
+
+	return &nu
+}
+
+// NormalizeString parses and normalizes rawURL
+func (n *Normalizer) NormalizeString(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	return n.Normalize(u).String(), nil
+}
+
+func (n *Normalizer) normalizeQuery(query string) string {
+	params := strings.Split(query, "&")
+
+	if n.Flags&NormalizePercentEncoding != 0 {
+		for i, param := range params {
+			params[i] = normalizePercentEncoding(param)
+		}
+	}
+
+	if n.Flags&RemoveTrackingParams != 0 {
+		tracking := n.TrackingParams
+		if tracking == nil {
+			tracking = DefaultTrackingParams
+		}
+
+		kept := params[:0]
+		for _, param := range params {
+			if param != "" && !isTrackingParam(paramName(param), tracking) {
+				kept = append(kept, param)
+			}
+		}
+		params = kept
+	}
+
+	if n.Flags&SortQuery != 0 {
+		sort.SliceStable(params, func(i, j int) bool {
+			return paramName(params[i]) < paramName(params[j])
+		})
+	}
+
+	return strings.Join(params, "&")
+}
+
+func paramName(param string) string {
+	name := param
+	if i := strings.IndexByte(param, '='); i >= 0 {
+		name = param[:i]
+	}
+	if unescaped, err := url.QueryUnescape(name); err == nil {
+		return unescaped
+	}
+	return name
+}
+
+func isTrackingParam(name string, tracking []string) bool {
+	name = strings.ToLower(name)
+	for _, t := range tracking {
+		if strings.HasSuffix(t, "*") {
+			if strings.HasPrefix(name, t[:len(t)-1]) {
+				return true
+			}
+		} else if name == t {
+			return true
+		}
+	}
+	return false
+}
+
+func splitHostPort(hostport string) (host, port string) {
+	host = hostport
+
+	colon := strings.LastIndexByte(host, ':')
+	if colon != -1 && strings.IndexByte(host[colon:], ']') == -1 {
+		host, port = host[:colon], host[colon+1:]
+	}
+
+	return
+}
+
+func isUnreserved(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+func normalizePercentEncoding(s string) string {
+	if strings.IndexByte(s, '%') < 0 {
+		return s
+	}
+
+	const upperhex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	b.Grow(len(s))
+
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			hi, ok1 := unhex(s[i+1])
+			lo, ok2 := unhex(s[i+2])
+			if ok1 && ok2 {
+				if c := hi<<4 | lo; isUnreserved(c) {
+					b.WriteByte(c)
+				} else {
+					b.WriteByte('%')
+					b.WriteByte(upperhex[hi])
+					b.WriteByte(upperhex[lo])
+				}
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+// removeDotSegments implements RFC 3986, section 5.2.4
+func removeDotSegments(path string) string {
+	if path == "" {
+		return path
+	}
+
+	var (
+		segments = strings.Split(path, "/")
+		out      = make([]string, 0, len(segments))
+	)
+
+	for i, seg := range segments {
+		last := i == len(segments)-1
+
+		switch seg {
+		case ".":
+			if last {
+				out = append(out, "")
+			}
+		case "..":
+			if len(out) > 1 || (len(out) == 1 && out[0] != "") {
+				out = out[:len(out)-1]
+			}
+			if last {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, seg)
+		}
+	}
+
+	result := strings.Join(out, "/")
+	if strings.HasPrefix(path, "/") && !strings.HasPrefix(result, "/") {
+		result = "/" + result
+	}
+	return result
+}
@@ -0,0 +1,53 @@
+package urlnorm
+
+import (
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	for _, c := range []struct {
+		flags    Flags
+		input    string
+		expected string
+	}{
+		{Safe, "HTTP://Example.com:80/a/../b?b=2&a=1", "http://example.com/b?b=2&a=1"},
+		{UsuallySafe, "HTTP://Example.com:80/a/../b?b=2&a=1", "http://example.com/b?a=1&b=2"},
+		{UsuallySafe, "http://example.com/b?a=1&b=2", "http://example.com/b?a=1&b=2"},
+		{Safe, "https://example.com:443", "https://example.com/"},
+		{Safe, "https://example.com:8443/", "https://example.com:8443/"},
+		{Safe, "http://example.com/%7euser/a%2fb/%c3%a9?q=%7e%2f", "http://example.com/~user/a%2Fb/%C3%A9?q=~%2F"},
+		{Safe, "http://example.com/./a/./b/../../c/.", "http://example.com/c/"},
+		{Safe, "http://example.com/../../a", "http://example.com/a"},
+		{Safe, "http://Bücher.example/?", "http://xn--bcher-kva.example/"},
+		{Safe, "http://user:pass@[::1]:80/#frag", "http://user:pass@[::1]/#frag"},
+		{UsuallySafe, "http://example.com/?utm_source=x&id=1&UTM_Medium=y&fbclid=z#top", "http://example.com/?id=1"},
+		{UsuallySafe, "http://example.com/?b=1&a=2&b=0", "http://example.com/?a=2&b=1&b=0"},
+		{Safe, "mailto:Someone@Example.com", "mailto:Someone@Example.com"},
+	} {
+		n := &Normalizer{Flags: c.flags}
+
+		actual, err := n.NormalizeString(c.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual != c.expected {
+			t.Log("bad normalization of", c.input, "; actual", actual, "expected", c.expected)
+			t.Fail()
+		}
+	}
+}
+
+func TestCustomTrackingParams(t *testing.T) {
+	n := &Normalizer{Flags: RemoveTrackingParams, TrackingParams: []string{"sid", "ref_*"}}
+
+	actual, err := n.NormalizeString("http://example.com/?utm_source=x&sid=1&ref_src=2&q=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "http://example.com/?utm_source=x&q=3"; actual != expected {
+		t.Log("bad normalization; actual", actual, "expected", expected)
+		t.Fail()
+	}
+}